@@ -0,0 +1,364 @@
+// Copyright 2014 com authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package com
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ErrRequired is wrapped by BindError when a required value is missing.
+var ErrRequired = errors.New("required value is missing")
+
+// BindError describes a struct field that cannot be bound.
+type BindError struct {
+	Field string // Go path of the field, e.g. "DB.Port".
+	Key   string // Key looked up in the source, e.g. "db.port".
+	Err   error
+}
+
+func (e *BindError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Key, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+var (
+	durationType = reflect.TypeOf(time.Duration(0))
+	timeType     = reflect.TypeOf(time.Time{})
+	bigIntType   = reflect.TypeOf(big.Int{})
+
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// isTextUnmarshaler returns true if pointer to t implements encoding.TextUnmarshaler.
+func isTextUnmarshaler(t reflect.Type) bool {
+	return reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+// BindFunc fills exported fields of the struct pointed by ptr with values
+// returned by lookup, converted by StrTo or by UnmarshalText of types that
+// implement encoding.TextUnmarshaler, e.g. ByteSize and net.IP.
+// Field options are set by tags:
+//
+//	Port  int       `com:"port,required"` // key name and options
+//	Level string    `com:"level" default:"info"`
+//	Tags  []string  `com:"tags" sep:";"` // separator of slice items, default is ","
+//	Start time.Time `layout:"2006-01-02"` // layout of time, default is time.RFC3339
+//	Skip  int       `com:"-"`
+//
+// Key name defaults to the field name. Fields of nested structs are looked up
+// with the key of the struct field and a dot as prefix, e.g. "db.port", except
+// for embedded structs without a tag. Empty values are treated as missing.
+// Fields without value or default are left untouched. Fields of a struct type
+// that is already being bound, e.g. Next of a linked list node, are skipped.
+//
+// All field errors are reported at once as a MultiError of *BindError.
+func BindFunc(ptr interface{}, lookup func(key string) []string) error {
+	return bind(ptr, lookup, func(prefix, name string) string {
+		if prefix == "" {
+			return name
+		}
+		return prefix + "." + name
+	})
+}
+
+// BindMap fills the struct pointed by ptr with values of m, see BindFunc for details.
+func BindMap(ptr interface{}, m map[string]string) error {
+	return BindFunc(ptr, func(key string) []string {
+		if v, ok := m[key]; ok {
+			return []string{v}
+		}
+		return nil
+	})
+}
+
+// BindValues fills the struct pointed by ptr with form values, see BindFunc for details.
+// All values of a key are used for slice fields, the first one otherwise.
+func BindValues(ptr interface{}, vals url.Values) error {
+	return BindFunc(ptr, func(key string) []string {
+		return vals[key]
+	})
+}
+
+// BindEnv fills the struct pointed by ptr with environment variables,
+// see BindFunc for details. Keys are upper-cased and nested keys are
+// joined by underscore, e.g. "DB_PORT".
+func BindEnv(ptr interface{}) error {
+	return bind(ptr, func(key string) []string {
+		if v, ok := os.LookupEnv(key); ok {
+			return []string{v}
+		}
+		return nil
+	}, func(prefix, name string) string {
+		if prefix != "" {
+			name = prefix + "_" + name
+		}
+		return strings.ToUpper(name)
+	})
+}
+
+type binder struct {
+	lookup func(key string) []string
+	join   func(prefix, name string) string
+	errs   MultiError
+	// binding holds struct types on the current path to stop at
+	// types referring to themselves, e.g. linked lists.
+	binding map[reflect.Type]bool
+}
+
+func bind(ptr interface{}, lookup func(string) []string, join func(string, string) string) error {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("bind: expect non-nil pointer to struct, got %T", ptr)
+	}
+
+	b := &binder{lookup: lookup, join: join, binding: make(map[reflect.Type]bool)}
+	b.bindStruct(v.Elem(), "", "")
+	if len(b.errs) > 0 {
+		return b.errs
+	}
+	return nil
+}
+
+// isNestedStruct returns true if fields of type t should be bound one by one.
+func isNestedStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && t != timeType && t != bigIntType && !isTextUnmarshaler(t)
+}
+
+// bindStruct returns true if any field of v has been found in the source.
+func (b *binder) bindStruct(v reflect.Value, prefix, path string) (set bool) {
+	t := v.Type()
+	if b.binding[t] {
+		return false
+	}
+	b.binding[t] = true
+	defer delete(b.binding, t)
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+
+		tag := f.Tag.Get("com")
+		if tag == "-" {
+			continue
+		}
+		name, required := tag, false
+		if i := strings.IndexByte(tag, ','); i >= 0 {
+			name = tag[:i]
+			for _, opt := range strings.Split(tag[i+1:], ",") {
+				required = required || opt == "required"
+			}
+		}
+
+		fieldPath := f.Name
+		if path != "" {
+			fieldPath = path + "." + f.Name
+		}
+		fv := v.Field(i)
+
+		if isNestedStruct(f.Type) {
+			nestedPrefix := prefix
+			if !f.Anonymous || name != "" {
+				if name == "" {
+					name = f.Name
+				}
+				nestedPrefix = b.join(prefix, name)
+			}
+			if b.bindNested(fv, nestedPrefix, fieldPath) {
+				set = true
+			}
+			continue
+		}
+		if !fv.CanSet() {
+			continue
+		}
+
+		if name == "" {
+			name = f.Name
+		}
+		key := b.join(prefix, name)
+		var vals []string
+		for _, val := range b.lookup(key) {
+			if val != "" {
+				vals = append(vals, val)
+			}
+		}
+		if len(vals) > 0 {
+			set = true
+		} else {
+			def, ok := f.Tag.Lookup("default")
+			if !ok {
+				if required {
+					b.errs = append(b.errs, &BindError{Field: fieldPath, Key: key, Err: ErrRequired})
+				}
+				continue
+			}
+			vals = []string{def}
+		}
+
+		if err := setField(fv, vals, f); err != nil {
+			b.errs = append(b.errs, &BindError{Field: fieldPath, Key: key, Err: err})
+		}
+	}
+	return set
+}
+
+// bindNested binds the struct or pointer to struct v. A nil pointer is only
+// allocated when any of its fields has been found in the source, otherwise
+// it is left nil and errors of its fields are discarded.
+func (b *binder) bindNested(v reflect.Value, prefix, path string) bool {
+	if v.Kind() != reflect.Ptr {
+		return b.bindStruct(v, prefix, path)
+	}
+	if !v.CanSet() {
+		return false
+	}
+
+	if !v.IsNil() {
+		return b.bindStruct(v.Elem(), prefix, path)
+	}
+
+	nv := reflect.New(v.Type().Elem())
+	n := len(b.errs)
+	if !b.bindStruct(nv.Elem(), prefix, path) {
+		b.errs = b.errs[:n]
+		return false
+	}
+	v.Set(nv)
+	return true
+}
+
+func setField(v reflect.Value, vals []string, f reflect.StructField) error {
+	if v.Kind() != reflect.Slice || isTextUnmarshaler(v.Type()) {
+		return setValue(v, vals[0], f)
+	}
+
+	sep := f.Tag.Get("sep")
+	if sep == "" {
+		sep = ","
+	}
+	var items []string
+	for _, val := range vals {
+		for _, item := range strings.Split(val, sep) {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+	}
+
+	sl := reflect.MakeSlice(v.Type(), len(items), len(items))
+	for i := range items {
+		if err := setValue(sl.Index(i), items[i], f); err != nil {
+			return err
+		}
+	}
+	v.Set(sl)
+	return nil
+}
+
+func setValue(v reflect.Value, s string, f reflect.StructField) error {
+	switch v.Type() {
+	case durationType:
+		d, err := StrTo(s).Duration()
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	case timeType:
+		layout := f.Tag.Get("layout")
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err := StrTo(s).Time(layout)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case bigIntType:
+		n, err := StrTo(s).BigInt()
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(n).Elem())
+		return nil
+	}
+	if v.CanAddr() {
+		if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(s))
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		nv := reflect.New(v.Type().Elem())
+		if err := setValue(nv.Elem(), s, f); err != nil {
+			return err
+		}
+		v.Set(nv)
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := StrTo(s).Bool()
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := StrTo(s).parseInt(v.Kind().String(), 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := StrTo(s).parseUint(v.Kind().String(), 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32:
+		n, err := StrTo(s).Float32()
+		if err != nil {
+			return err
+		}
+		v.SetFloat(float64(n))
+	case reflect.Float64:
+		n, err := StrTo(s).Float64()
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+// Copyright 2014 com authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package com
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+type bindDB struct {
+	Host string `com:"host" default:"localhost"`
+	Port int    `com:"port,required"`
+}
+
+type bindCommon struct {
+	Debug bool `com:"debug"`
+}
+
+type bindConfig struct {
+	bindCommon
+	Name    string
+	Workers uint8         `com:"workers" default:"4"`
+	Ratio   float64       `com:"ratio"`
+	Timeout time.Duration `com:"timeout"`
+	Since   time.Time     `com:"since" layout:"2006-01-02"`
+	Tags    []string      `com:"tags"`
+	Ports   []int         `com:"ports" sep:" "`
+	Limit   *int          `com:"limit"`
+	DB      bindDB        `com:"db"`
+	Cache   *bindDB       `com:"cache"`
+	Max     ByteSize      `com:"max"`
+	Addr    net.IP        `com:"addr"`
+	Ignored string        `com:"-"`
+	secret  string
+}
+
+type bindNode struct {
+	Name string
+	Next *bindNode
+}
+
+func TestBindMap(t *testing.T) {
+	Convey("Bind struct from map", t, func() {
+		var cfg bindConfig
+		err := BindMap(&cfg, map[string]string{
+			"debug":   "true",
+			"Name":    "app",
+			"ratio":   "0.5",
+			"timeout": "3s",
+			"since":   "2014-05-06",
+			"tags":    "a, b,,c",
+			"ports":   "80 443",
+			"limit":   "10",
+			"db.port": "5432",
+			"max":     "10MiB",
+			"addr":    "10.0.0.1",
+			"Ignored": "x",
+			"secret":  "x",
+		})
+		So(err, ShouldBeNil)
+		So(cfg.Debug, ShouldBeTrue)
+		So(cfg.Name, ShouldEqual, "app")
+		So(cfg.Workers, ShouldEqual, 4)
+		So(cfg.Ratio, ShouldEqual, 0.5)
+		So(cfg.Timeout, ShouldEqual, 3*time.Second)
+		So(cfg.Since.Equal(time.Date(2014, 5, 6, 0, 0, 0, 0, time.UTC)), ShouldBeTrue)
+		So(cfg.Tags, ShouldResemble, []string{"a", "b", "c"})
+		So(cfg.Ports, ShouldResemble, []int{80, 443})
+		So(*cfg.Limit, ShouldEqual, 10)
+		So(cfg.DB, ShouldResemble, bindDB{Host: "localhost", Port: 5432})
+		So(cfg.Cache, ShouldBeNil)
+		So(cfg.Max, ShouldEqual, 10*MByte)
+		So(cfg.Addr.Equal(net.IPv4(10, 0, 0, 1)), ShouldBeTrue)
+		So(cfg.Ignored, ShouldBeEmpty)
+		So(cfg.secret, ShouldBeEmpty)
+	})
+
+	Convey("Report all field errors at once", t, func() {
+		var cfg bindConfig
+		err := BindMap(&cfg, map[string]string{
+			"workers":    "300",
+			"ports":      "80 x",
+			"cache.host": "redis",
+		})
+		So(err, ShouldNotBeNil)
+
+		errs, ok := err.(MultiError)
+		So(ok, ShouldBeTrue)
+		So(errs, ShouldHaveLength, 4)
+		So(errs[0].Error(), ShouldEqual, `workers: cannot convert "300" to uint8: value out of range`)
+		So(errs[1].Error(), ShouldEqual, `ports: cannot convert "x" to int: invalid syntax`)
+		So(errors.Is(errs[1], strconv.ErrSyntax), ShouldBeTrue)
+
+		var be *BindError
+		So(errors.As(errs[2], &be), ShouldBeTrue)
+		So(be.Field, ShouldEqual, "DB.Port")
+		So(be.Key, ShouldEqual, "db.port")
+		So(errors.Is(be, ErrRequired), ShouldBeTrue)
+
+		So(errs[3].Error(), ShouldEqual, "cache.port: required value is missing")
+		So(cfg.Cache, ShouldNotBeNil)
+		So(cfg.Cache.Host, ShouldEqual, "redis")
+	})
+
+	Convey("Stop at self-referential structs", t, func() {
+		var n bindNode
+		So(BindMap(&n, map[string]string{"Name": "a", "Next.Name": "b"}), ShouldBeNil)
+		So(n.Name, ShouldEqual, "a")
+		So(n.Next, ShouldBeNil)
+	})
+
+	Convey("Reject non-struct pointer", t, func() {
+		var n int
+		So(BindMap(&n, nil), ShouldNotBeNil)
+		So(BindMap(bindConfig{}, nil), ShouldNotBeNil)
+	})
+}
+
+func TestBindValues(t *testing.T) {
+	Convey("Bind struct from form values", t, func() {
+		var cfg bindConfig
+		err := BindValues(&cfg, url.Values{
+			"Name":    {"first", "second"},
+			"tags":    {"a", "b,c"},
+			"db.port": {"3306"},
+			"ratio":   {""},
+		})
+		So(err, ShouldBeNil)
+		So(cfg.Name, ShouldEqual, "first")
+		So(cfg.Tags, ShouldResemble, []string{"a", "b", "c"})
+		So(cfg.DB.Port, ShouldEqual, 3306)
+		So(cfg.Ratio, ShouldEqual, 0)
+	})
+}
+
+func TestBindEnv(t *testing.T) {
+	Convey("Bind struct from environment variables", t, func() {
+		os.Setenv("DB_PORT", "5433")
+		os.Setenv("CACHE_HOST", "redis")
+		os.Setenv("CACHE_PORT", "6379")
+		defer os.Unsetenv("DB_PORT")
+		defer os.Unsetenv("CACHE_HOST")
+		defer os.Unsetenv("CACHE_PORT")
+
+		var cfg bindConfig
+		So(BindEnv(&cfg), ShouldBeNil)
+		So(cfg.DB.Port, ShouldEqual, 5433)
+		So(*cfg.Cache, ShouldResemble, bindDB{Host: "redis", Port: 6379})
+	})
+}
@@ -19,10 +19,18 @@ package com
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"os"
 	"os/exec"
+	"regexp"
 	"runtime"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 // ExecCmdDirBytes executes system command in given directory
@@ -59,6 +67,1156 @@ func ExecCmd(cmdName string, args ...string) (string, string, error) {
 	return ExecCmdDir("", cmdName, args...)
 }
 
+// ExecCmdDirString executes command line in given directory without a shell,
+// the command line is split into program name and arguments by SplitShellArgs
+// without variable expansion. It returns stdout, stderr in string type,
+// along with possible error.
+func ExecCmdDirString(dir, cmdline string) (string, string, error) {
+	args, err := splitCmdline(cmdline, nil)
+	if err != nil {
+		return "", "", err
+	}
+	return ExecCmdDir(dir, args[0], args[1:]...)
+}
+
+// ExecCmdString executes command line without a shell.
+// See ExecCmdDirString for details.
+func ExecCmdString(cmdline string) (string, string, error) {
+	return ExecCmdDirString("", cmdline)
+}
+
+// splitCmdline splits command line and makes sure it is not empty.
+func splitCmdline(cmdline string, vars map[string]string) ([]string, error) {
+	args, err := SplitShellArgs(cmdline, vars)
+	if err != nil {
+		return nil, err
+	} else if len(args) == 0 {
+		return nil, errors.New("empty command line")
+	}
+	return args, nil
+}
+
+// ExecErrorKind describes why a command did not complete successfully.
+type ExecErrorKind int
+
+const (
+	// ExecExited means the command exited with non-zero status.
+	ExecExited ExecErrorKind = iota + 1
+	// ExecTimedOut means the context deadline passed before the command exited.
+	ExecTimedOut
+	// ExecCanceled means the context was canceled before the command exited.
+	ExecCanceled
+)
+
+func (k ExecErrorKind) String() string {
+	switch k {
+	case ExecExited:
+		return "exited"
+	case ExecTimedOut:
+		return "timed out"
+	case ExecCanceled:
+		return "canceled"
+	}
+	return "unknown"
+}
+
+// ExecError is returned by context-aware command functions when the command
+// exits with non-zero status, times out or is canceled.
+type ExecError struct {
+	Kind ExecErrorKind
+	Name string
+	Args []string
+	Err  error // Underlying *exec.ExitError or context error.
+
+	// Stderr is the trimmed tail of standard error output,
+	// it is only set by Result.CheckSuccess.
+	Stderr string
+}
+
+func (e *ExecError) Error() string {
+	msg := fmt.Sprintf("%s: %s", e.Name, e.Kind)
+	if e.Kind == ExecExited {
+		msg = fmt.Sprintf("%s: %v", e.Name, e.Err)
+	}
+	if len(e.Stderr) > 0 {
+		msg += ": " + e.Stderr
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error.
+func (e *ExecError) Unwrap() error {
+	return e.Err
+}
+
+// Timeout returns true if the command was killed because of a deadline.
+func (e *ExecError) Timeout() bool {
+	return e.Kind == ExecTimedOut
+}
+
+// Canceled returns true if the command was killed because of cancellation.
+func (e *ExecError) Canceled() bool {
+	return e.Kind == ExecCanceled
+}
+
+// ExitCode returns exit status of the command,
+// or -1 if it did not exit by itself.
+func (e *ExecError) ExitCode() int {
+	if ee, ok := e.Err.(*exec.ExitError); ok && e.Kind == ExecExited {
+		return ee.ExitCode()
+	}
+	return -1
+}
+
+func newExecError(kind ExecErrorKind, cmd *exec.Cmd, err error) *ExecError {
+	return &ExecError{
+		Kind: kind,
+		Name: cmd.Args[0],
+		Args: cmd.Args[1:],
+		Err:  err,
+	}
+}
+
+// startCmd starts the command in its own process group.
+func startCmd(cmd *exec.Cmd) error {
+	setProcessGroup(cmd)
+	return cmd.Start()
+}
+
+// waitCmd waits for a started command to exit. If ctx is done first,
+// it kills the whole process group of the command and waits for it to go away.
+func waitCmd(ctx context.Context, cmd *exec.Cmd) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err := <-done:
+		if _, ok := err.(*exec.ExitError); ok {
+			return newExecError(ExecExited, cmd, err)
+		}
+		return err
+	case <-ctx.Done():
+		killProcessGroup(cmd)
+		<-done
+
+		kind := ExecCanceled
+		if ctx.Err() == context.DeadlineExceeded {
+			kind = ExecTimedOut
+		}
+		return newExecError(kind, cmd, ctx.Err())
+	}
+}
+
+// runCmd starts the command and waits for it with waitCmd.
+func runCmd(ctx context.Context, cmd *exec.Cmd) error {
+	if err := startCmd(cmd); err != nil {
+		return err
+	}
+	return waitCmd(ctx, cmd)
+}
+
+// ExecCmdDirBytesContext executes system command in given directory
+// and return stdout, stderr in bytes type, along with possible error.
+// The command and all of its children are killed when ctx is done.
+// Errors other than failing to start the command are of type *ExecError.
+func ExecCmdDirBytesContext(ctx context.Context, dir, cmdName string, args ...string) ([]byte, []byte, error) {
+	c := NewCmd(cmdName, args...)
+	c.Dir = dir
+	r, err := c.RunContext(ctx)
+	return r.Stdout, r.Stderr, err
+}
+
+// ExecCmdBytesContext executes system command
+// and return stdout, stderr in bytes type, along with possible error.
+// See ExecCmdDirBytesContext for how ctx is used.
+func ExecCmdBytesContext(ctx context.Context, cmdName string, args ...string) ([]byte, []byte, error) {
+	return ExecCmdDirBytesContext(ctx, "", cmdName, args...)
+}
+
+// ExecCmdDirContext executes system command in given directory
+// and return stdout, stderr in string type, along with possible error.
+// See ExecCmdDirBytesContext for how ctx is used.
+func ExecCmdDirContext(ctx context.Context, dir, cmdName string, args ...string) (string, string, error) {
+	bufOut, bufErr, err := ExecCmdDirBytesContext(ctx, dir, cmdName, args...)
+	return string(bufOut), string(bufErr), err
+}
+
+// ExecCmdContext executes system command
+// and return stdout, stderr in string type, along with possible error.
+// See ExecCmdDirBytesContext for how ctx is used.
+func ExecCmdContext(ctx context.Context, cmdName string, args ...string) (string, string, error) {
+	return ExecCmdDirContext(ctx, "", cmdName, args...)
+}
+
+// Cmd represents a system command to be executed.
+type Cmd struct {
+	Name string   // Name or path of the program.
+	Args []string // Arguments, not including the program name.
+	Dir  string   // Working directory, empty means current directory.
+
+	// Env holds variables in the form "key=value" that are added to
+	// or override the environment of the command.
+	Env []string
+	// Unsetenv holds names of variables removed from the environment,
+	// it is applied after Env.
+	Unsetenv []string
+	// CleanEnv makes the command start with SanitizedEnv instead of
+	// inheriting the environment of current process.
+	CleanEnv bool
+
+	// Stdin is used as standard input if not nil,
+	// otherwise StdinBytes is used if not nil.
+	Stdin      io.Reader
+	StdinBytes []byte
+
+	// Stdout and Stderr receive output of the command as it is produced.
+	// When both are the same writer, it is never written concurrently.
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// StdoutLine and StderrLine are called for each line of output
+	// as it is produced, without the trailing line break.
+	// They are never called concurrently, so one function can handle both.
+	StdoutLine func(line string)
+	StderrLine func(line string)
+
+	// MaxOutput limits bytes of each stream kept in Result if it is positive,
+	// only the last MaxOutput bytes are kept.
+	MaxOutput int
+
+	// Retry is used to retry the command when it fails if not nil.
+	// Output is written to writers and line handlers again for every attempt,
+	// so use StdinBytes rather than Stdin for commands that read input.
+	Retry *RetryPolicy
+}
+
+// NewCmd returns a Cmd to execute the named program with given arguments.
+func NewCmd(name string, args ...string) *Cmd {
+	return &Cmd{
+		Name: name,
+		Args: args,
+	}
+}
+
+// ParseCmd returns a Cmd from command line split by SplitShellArgs,
+// variables are expanded from vars if it is not nil.
+func ParseCmd(cmdline string, vars map[string]string) (*Cmd, error) {
+	args, err := splitCmdline(cmdline, vars)
+	if err != nil {
+		return nil, err
+	}
+	return NewCmd(args[0], args[1:]...), nil
+}
+
+// String returns the command line with arguments quoted by ShellQuote,
+// which is suitable for display and logging.
+func (c *Cmd) String() string {
+	return JoinShellArgs(append([]string{c.Name}, c.Args...))
+}
+
+// Result describes a finished command.
+type Result struct {
+	Argv     []string      // Resolved program path followed by arguments.
+	ExitCode int           // -1 if the process did not exit by itself.
+	Signal   os.Signal     // Signal that terminated the process, if any.
+	Duration time.Duration // Wall-clock time from start to exit.
+	Stdout   []byte
+	Stderr   []byte
+	Attempts int // Number of times the command was run.
+
+	// Err is the error returned by Run, it is of type *ExecError
+	// unless the command failed to start, or *ToolError if the program
+	// was not found.
+	Err error
+}
+
+// Success returns true if the command exited with zero status.
+func (r *Result) Success() bool {
+	return r.Err == nil && r.ExitCode == 0
+}
+
+// stderrTailSize is the maximum length of stderr included in error messages.
+const stderrTailSize = 1024
+
+// stderrTail returns trimmed last part of stderr output that fits in max bytes.
+func stderrTail(stderr []byte, max int) string {
+	tail := bytes.TrimSpace(stderr)
+	if len(tail) > max {
+		tail = tail[len(tail)-max:]
+		// Avoid starting with half of a line.
+		if i := bytes.IndexByte(tail, '\n'); i > -1 && i < len(tail)-1 {
+			tail = tail[i+1:]
+		}
+		tail = append([]byte("..."), tail...)
+	}
+	return string(tail)
+}
+
+// CheckSuccess returns nil if the command exited with zero status,
+// otherwise an error that includes the tail of stderr output.
+func (r *Result) CheckSuccess() error {
+	if r.Success() {
+		return nil
+	}
+	e, ok := r.Err.(*ExecError)
+	if !ok {
+		return r.Err
+	}
+	ce := *e
+	ce.Stderr = stderrTail(r.Stderr, stderrTailSize)
+	return &ce
+}
+
+// Run executes the command and waits for it to finish.
+// See RunContext for details.
+func (c *Cmd) Run() (*Result, error) {
+	return c.RunContext(context.Background())
+}
+
+// RunContext executes the command and waits for it to finish,
+// the command and all of its children are killed when ctx is done.
+// It always returns a non-nil Result, the error is the same as Result.Err.
+func (c *Cmd) RunContext(ctx context.Context) (*Result, error) {
+	r := c.runRetry(ctx, c.Retry)
+	return r, r.Err
+}
+
+// runOnce runs the command a single time.
+func (c *Cmd) runOnce(ctx context.Context) *Result {
+	e := c.prepare()
+	e.start = time.Now()
+	err := runCmd(ctx, e.cmd)
+	if errors.Is(err, exec.ErrNotFound) {
+		err = &ToolError{Kind: ToolNotInstalled, Name: c.Name, Err: err}
+	}
+	return e.result(err)
+}
+
+// runRetry runs the command until it succeeds or policy gives up.
+func (c *Cmd) runRetry(ctx context.Context, policy *RetryPolicy) *Result {
+	var r *Result
+	for attempt := 1; ; attempt++ {
+		r = c.runOnce(ctx)
+		r.Attempts = attempt
+		if r.Success() || ctx.Err() != nil || !policy.retry(attempt, r) {
+			return r
+		}
+
+		timer := time.NewTimer(policy.delay(attempt))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return r
+		}
+	}
+}
+
+// cmdExec is an exec.Cmd prepared from Cmd along with its output collectors.
+type cmdExec struct {
+	c       *Cmd
+	cmd     *exec.Cmd
+	bufOut  outputBuffer
+	bufErr  outputBuffer
+	lineOut io.Writer
+	lineErr io.Writer
+	start   time.Time
+}
+
+func (c *Cmd) prepare() *cmdExec {
+	lineLock := new(sync.Mutex)
+	e := &cmdExec{
+		c:       c,
+		bufOut:  newOutputBuffer(c.MaxOutput),
+		bufErr:  newOutputBuffer(c.MaxOutput),
+		lineOut: newLineWriter(c.StdoutLine, lineLock),
+		lineErr: newLineWriter(c.StderrLine, lineLock),
+	}
+
+	stdout, stderr := c.Stdout, c.Stderr
+	if stdout != nil && sameWriter(stdout, stderr) {
+		stdout = &lockedWriter{w: stdout}
+		stderr = stdout
+	}
+
+	e.cmd = exec.Command(c.Name, c.Args...)
+	e.cmd.Dir = c.Dir
+	e.cmd.Env = c.environ()
+	e.cmd.Stdin = c.Stdin
+	if e.cmd.Stdin == nil && c.StdinBytes != nil {
+		e.cmd.Stdin = bytes.NewReader(c.StdinBytes)
+	}
+	e.cmd.Stdout = teeWriter(e.bufOut, stdout, e.lineOut)
+	e.cmd.Stderr = teeWriter(e.bufErr, stderr, e.lineErr)
+	return e
+}
+
+// result returns Result of the finished command.
+func (e *cmdExec) result(err error) *Result {
+	flushLines(e.lineOut)
+	flushLines(e.lineErr)
+
+	r := &Result{
+		Argv:     append([]string{e.cmd.Path}, e.c.Args...),
+		ExitCode: -1,
+		Stdout:   e.bufOut.Bytes(),
+		Stderr:   e.bufErr.Bytes(),
+		Err:      err,
+	}
+	if !e.start.IsZero() {
+		r.Duration = time.Since(e.start)
+	}
+	if e.cmd.ProcessState != nil {
+		r.ExitCode = e.cmd.ProcessState.ExitCode()
+		r.Signal = exitSignal(e.cmd.ProcessState)
+	}
+	return r
+}
+
+// SanitizedEnvKeys lists variables that are kept by SanitizedEnv.
+var SanitizedEnvKeys = []string{
+	"PATH", "HOME", "USER", "LOGNAME", "TMPDIR",
+	// Windows programs may not start without these.
+	"SYSTEMROOT", "SYSTEMDRIVE", "WINDIR", "COMSPEC", "PATHEXT",
+	"TEMP", "TMP", "USERPROFILE",
+}
+
+// SanitizedEnv returns a minimal environment that only contains
+// variables of current process listed in SanitizedEnvKeys.
+func SanitizedEnv() []string {
+	env := make([]string, 0, len(SanitizedEnvKeys))
+	for _, kv := range os.Environ() {
+		key := envKey(kv)
+		for _, k := range SanitizedEnvKeys {
+			if strings.EqualFold(key, k) {
+				env = append(env, kv)
+				break
+			}
+		}
+	}
+	return env
+}
+
+// envKey returns the variable name of "key=value" pair.
+func envKey(kv string) string {
+	// Windows has hidden variables like "=C:=C:\\".
+	start := 0
+	if strings.HasPrefix(kv, "=") {
+		start = 1
+	}
+	if i := strings.Index(kv[start:], "="); i > -1 {
+		return kv[:start+i]
+	}
+	return kv
+}
+
+// normEnvKey returns the key used to compare variable names,
+// which are case-insensitive on Windows.
+func normEnvKey(key string) string {
+	if runtime.GOOS == "windows" {
+		return strings.ToUpper(key)
+	}
+	return key
+}
+
+// MergeEnv returns base environment with variables of overlay
+// added or replaced, and variables named in unset removed.
+// Order of variables is kept, and the last duplicate wins.
+func MergeEnv(base, overlay, unset []string) []string {
+	env := make([]string, 0, len(base)+len(overlay))
+	index := make(map[string]int, cap(env))
+	for _, list := range [][]string{base, overlay} {
+		for _, kv := range list {
+			key := normEnvKey(envKey(kv))
+			if i, ok := index[key]; ok {
+				env[i] = kv
+				continue
+			}
+			index[key] = len(env)
+			env = append(env, kv)
+		}
+	}
+
+	if len(unset) == 0 {
+		return env
+	}
+	removed := make(map[string]bool, len(unset))
+	for _, key := range unset {
+		removed[normEnvKey(key)] = true
+	}
+	kept := env[:0]
+	for _, kv := range env {
+		if !removed[normEnvKey(envKey(kv))] {
+			kept = append(kept, kv)
+		}
+	}
+	return kept
+}
+
+// environ returns environment of the command, or nil to inherit
+// environment of current process unchanged.
+func (c *Cmd) environ() []string {
+	if !c.CleanEnv && len(c.Env) == 0 && len(c.Unsetenv) == 0 {
+		return nil
+	}
+
+	base := os.Environ()
+	if c.CleanEnv {
+		base = SanitizedEnv()
+	}
+	return MergeEnv(base, c.Env, c.Unsetenv)
+}
+
+// outputBuffer is a writer that keeps the output of a command.
+type outputBuffer interface {
+	io.Writer
+	Bytes() []byte
+}
+
+func newOutputBuffer(max int) outputBuffer {
+	if max > 0 {
+		return NewRingBuffer(max)
+	}
+	return new(bytes.Buffer)
+}
+
+// teeWriter returns a writer that duplicates its writes to all non-nil writers.
+func teeWriter(writers ...io.Writer) io.Writer {
+	ws := make([]io.Writer, 0, len(writers))
+	for _, w := range writers {
+		if w != nil {
+			ws = append(ws, w)
+		}
+	}
+	if len(ws) == 1 {
+		return ws[0]
+	}
+	return io.MultiWriter(ws...)
+}
+
+// sameWriter reports whether two writers are the same,
+// it returns false for values that cannot be compared.
+func sameWriter(a, b io.Writer) (same bool) {
+	defer func() {
+		if recover() != nil {
+			same = false
+		}
+	}()
+	return a == b
+}
+
+// lockedWriter serializes writes to the underlying writer.
+type lockedWriter struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.w.Write(p)
+}
+
+// maxLineSize is the length after which a line without break is
+// passed to the line handler anyway.
+const maxLineSize = 64 * 1024
+
+// lineWriter calls a function for each line written to it,
+// calls are serialized by lock shared with other line writers.
+type lineWriter struct {
+	fn   func(string)
+	lock *sync.Mutex
+	buf  []byte
+}
+
+// newLineWriter returns a nil writer if fn is nil.
+func newLineWriter(fn func(string), lock *sync.Mutex) io.Writer {
+	if fn == nil {
+		return nil
+	}
+	return &lineWriter{fn: fn, lock: lock}
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.emit(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	if len(w.buf) >= maxLineSize {
+		w.emit(w.buf)
+		w.buf = nil
+	}
+	return len(p), nil
+}
+
+// flushLines passes remaining data that has no line break to the line handler.
+func flushLines(w io.Writer) {
+	if lw, ok := w.(*lineWriter); ok && len(lw.buf) > 0 {
+		lw.emit(lw.buf)
+		lw.buf = nil
+	}
+}
+
+func (w *lineWriter) emit(line []byte) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.fn(string(bytes.TrimSuffix(line, []byte("\r"))))
+}
+
+// RingBuffer is a writer that keeps only the last bytes written to it.
+// It is safe for concurrent use.
+type RingBuffer struct {
+	lock    sync.Mutex
+	buf     []byte
+	pos     int
+	full    bool
+	written int64
+}
+
+// NewRingBuffer returns a RingBuffer that keeps the last size bytes.
+func NewRingBuffer(size int) *RingBuffer {
+	if size < 0 {
+		size = 0
+	}
+	return &RingBuffer{buf: make([]byte, size)}
+}
+
+// Write always succeeds, older data is dropped when the buffer is full.
+func (r *RingBuffer) Write(p []byte) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	n := len(p)
+	r.written += int64(n)
+	if len(r.buf) == 0 {
+		return n, nil
+	}
+
+	if len(p) > len(r.buf) {
+		p = p[len(p)-len(r.buf):]
+	}
+	for len(p) > 0 {
+		c := copy(r.buf[r.pos:], p)
+		p = p[c:]
+		r.pos += c
+		if r.pos == len(r.buf) {
+			r.pos = 0
+			r.full = true
+		}
+	}
+	return n, nil
+}
+
+// Bytes returns a copy of the kept data in the order it was written.
+func (r *RingBuffer) Bytes() []byte {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if !r.full {
+		return append([]byte(nil), r.buf[:r.pos]...)
+	}
+	p := make([]byte, 0, len(r.buf))
+	p = append(p, r.buf[r.pos:]...)
+	return append(p, r.buf[:r.pos]...)
+}
+
+// Written returns total number of bytes written, including dropped ones.
+func (r *RingBuffer) Written() int64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.written
+}
+
+// Truncated returns true if some of the written data has been dropped.
+func (r *RingBuffer) Truncated() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.written > int64(len(r.buf))
+}
+
+// Pipeline runs commands with standard output of each one connected to
+// standard input of the next one, like a shell pipe but without a shell.
+type Pipeline struct {
+	Cmds []*Cmd
+
+	// Dir, Env, Unsetenv and CleanEnv are defaults for all commands.
+	// Dir is used when a command has none, environment settings of
+	// a command are applied after the ones of the pipeline.
+	Dir      string
+	Env      []string
+	Unsetenv []string
+	CleanEnv bool
+}
+
+// NewPipeline returns a Pipeline of given commands. Only the first command
+// reads its own Stdin, and only the last one has its stdout collected.
+func NewPipeline(cmds ...*Cmd) *Pipeline {
+	return &Pipeline{Cmds: cmds}
+}
+
+// PipelineResult describes a finished pipeline.
+type PipelineResult struct {
+	Stages   []*Result // Results of each command, in order.
+	Duration time.Duration
+
+	// Err is the error of the last command that failed (pipefail semantics),
+	// or the error that prevented the pipeline from starting.
+	Err error
+}
+
+// Stdout returns standard output of the last command.
+func (r *PipelineResult) Stdout() []byte {
+	if len(r.Stages) == 0 {
+		return nil
+	}
+	return r.Stages[len(r.Stages)-1].Stdout
+}
+
+// ExitCode returns exit status of the last command that failed,
+// or 0 if all commands succeeded.
+func (r *PipelineResult) ExitCode() int {
+	for i := len(r.Stages) - 1; i >= 0; i-- {
+		if !r.Stages[i].Success() {
+			return r.Stages[i].ExitCode
+		}
+	}
+	return 0
+}
+
+// CheckSuccess returns nil if all commands succeeded, otherwise the
+// error of the last command that failed with the tail of its stderr output.
+func (r *PipelineResult) CheckSuccess() error {
+	for i := len(r.Stages) - 1; i >= 0; i-- {
+		if err := r.Stages[i].CheckSuccess(); err != nil {
+			return err
+		}
+	}
+	return r.Err
+}
+
+// stage returns a copy of the command with defaults of the pipeline applied.
+func (p *Pipeline) stage(c *Cmd) *Cmd {
+	sc := *c
+	if len(sc.Dir) == 0 {
+		sc.Dir = p.Dir
+	}
+	sc.Env = append(append([]string{}, p.Env...), c.Env...)
+	sc.Unsetenv = append(append([]string{}, p.Unsetenv...), c.Unsetenv...)
+	sc.CleanEnv = p.CleanEnv || c.CleanEnv
+	return &sc
+}
+
+// Run executes the pipeline and waits for all commands to finish.
+// See RunContext for details.
+func (p *Pipeline) Run() (*PipelineResult, error) {
+	return p.RunContext(context.Background())
+}
+
+// RunContext executes the pipeline and waits for all commands to finish,
+// all commands and their children are killed when ctx is done.
+// It always returns a non-nil PipelineResult, the error is the same as PipelineResult.Err.
+func (p *Pipeline) RunContext(ctx context.Context) (*PipelineResult, error) {
+	if len(p.Cmds) == 0 {
+		err := errors.New("empty pipeline")
+		return &PipelineResult{Err: err}, err
+	}
+
+	execs := make([]*cmdExec, len(p.Cmds))
+	for i, c := range p.Cmds {
+		execs[i] = p.stage(c).prepare()
+	}
+
+	// Connect commands with OS pipes so data does not go through this process.
+	pipes := make([]*os.File, 0, 2*(len(execs)-1))
+	closePipes := func() {
+		for _, f := range pipes {
+			f.Close()
+		}
+	}
+	for i := 0; i < len(execs)-1; i++ {
+		r, w, err := os.Pipe()
+		if err != nil {
+			closePipes()
+			return p.result(execs, make([]error, len(execs)), time.Now(), err)
+		}
+		pipes = append(pipes, r, w)
+		execs[i].cmd.Stdout = w
+		execs[i+1].cmd.Stdin = r
+	}
+
+	start := time.Now()
+	errs := make([]error, len(execs))
+	for i, e := range execs {
+		e.start = time.Now()
+		if err := startCmd(e.cmd); err != nil {
+			closePipes()
+			for _, started := range execs[:i] {
+				killProcessGroup(started.cmd)
+				started.cmd.Wait()
+			}
+			errs[i] = err
+			return p.result(execs, errs, start, err)
+		}
+	}
+	// Commands hold their own copies, ours must be closed to let EOF through.
+	closePipes()
+
+	var wg sync.WaitGroup
+	for i, e := range execs {
+		wg.Add(1)
+		go func(i int, e *cmdExec) {
+			defer wg.Done()
+			errs[i] = waitCmd(ctx, e.cmd)
+		}(i, e)
+	}
+	wg.Wait()
+
+	var err error
+	for i := len(errs) - 1; i >= 0; i-- {
+		if errs[i] != nil {
+			err = errs[i]
+			break
+		}
+	}
+	return p.result(execs, errs, start, err)
+}
+
+func (p *Pipeline) result(execs []*cmdExec, errs []error, start time.Time, err error) (*PipelineResult, error) {
+	r := &PipelineResult{
+		Stages:   make([]*Result, len(execs)),
+		Duration: time.Since(start),
+		Err:      err,
+	}
+	for i, e := range execs {
+		r.Stages[i] = e.result(errs[i])
+	}
+	return r, err
+}
+
+// RetryPolicy describes when and how often a failed command is retried.
+type RetryPolicy struct {
+	// Attempts is the maximum number of times to run the command.
+	Attempts int
+	// Backoff is the delay before the second attempt.
+	Backoff time.Duration
+	// Multiplier is applied to the delay after every attempt,
+	// values not greater than 1 mean a constant delay.
+	Multiplier float64
+	// MaxBackoff limits the delay if it is positive.
+	MaxBackoff time.Duration
+	// Retryable reports whether the failed result should be retried,
+	// nil means any failure is retried.
+	Retryable func(r *Result) bool
+}
+
+// retry reports whether the command should be run again after given attempt.
+func (p *RetryPolicy) retry(attempt int, r *Result) bool {
+	if p == nil || attempt >= p.Attempts {
+		return false
+	}
+	return p.Retryable == nil || p.Retryable(r)
+}
+
+// delay returns the time to wait after given attempt.
+func (p *RetryPolicy) delay(attempt int) time.Duration {
+	d := float64(p.Backoff)
+	if p.Multiplier > 1 {
+		for i := 1; i < attempt; i++ {
+			d *= p.Multiplier
+			if p.MaxBackoff > 0 && d >= float64(p.MaxBackoff) {
+				break
+			}
+		}
+	}
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	return time.Duration(d)
+}
+
+// MultiError holds multiple errors.
+type MultiError []error
+
+func (e MultiError) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	msgs := make([]string, len(e))
+	for i := range e {
+		msgs[i] = e[i].Error()
+	}
+	return fmt.Sprintf("%d errors occurred: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// CmdRunner runs many commands with bounded concurrency.
+type CmdRunner struct {
+	// Parallel is the maximum number of commands running at the same time,
+	// values less than 1 mean the number of CPUs.
+	Parallel int
+	// Retry is used for commands that have no RetryPolicy of their own.
+	Retry *RetryPolicy
+	// StopOnError makes the runner kill running commands and skip
+	// remaining ones after the first failure.
+	StopOnError bool
+}
+
+// Run executes all commands and waits for them to finish.
+// See RunContext for details.
+func (r *CmdRunner) Run(cmds ...*Cmd) ([]*Result, error) {
+	return r.RunContext(context.Background(), cmds...)
+}
+
+// RunContext executes all commands and waits for them to finish.
+// Results are returned in the same order as commands, the result
+// is nil for a command that was skipped because of StopOnError or ctx.
+// The error is a MultiError with errors of Result.CheckSuccess of all failed commands.
+func (r *CmdRunner) RunContext(ctx context.Context, cmds ...*Cmd) ([]*Result, error) {
+	parallel := r.Parallel
+	if parallel < 1 {
+		parallel = runtime.NumCPU()
+	}
+	if parallel > len(cmds) {
+		parallel = len(cmds)
+	}
+
+	parent := ctx
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]*Result, len(cmds))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+				policy := cmds[i].Retry
+				if policy == nil {
+					policy = r.Retry
+				}
+				results[i] = cmds[i].runRetry(ctx, policy)
+				if r.StopOnError && !results[i].Success() {
+					cancel()
+				}
+			}
+		}()
+	}
+
+dispatch:
+	for i := range cmds {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	var errs MultiError
+	for _, res := range results {
+		if res == nil {
+			continue
+		}
+		if err := res.CheckSuccess(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if parent.Err() != nil {
+		errs = append(errs, parent.Err())
+	}
+	if len(errs) > 0 {
+		return results, errs
+	}
+	return results, nil
+}
+
+// ToolErrorKind describes why a program cannot be used.
+type ToolErrorKind int
+
+const (
+	// ToolNotInstalled means the program cannot be found.
+	ToolNotInstalled ToolErrorKind = iota + 1
+	// ToolTooOld means version of the program is lower than required.
+	ToolTooOld
+	// ToolUnknownVersion means version of the program cannot be detected.
+	ToolUnknownVersion
+)
+
+// ToolError is returned when a required program is not installed or too old.
+type ToolError struct {
+	Kind       ToolErrorKind
+	Name       string
+	Path       string // Resolved path, if found.
+	Version    string // Detected version, if any.
+	MinVersion string
+	Err        error // Underlying error, if any.
+}
+
+func (e *ToolError) Error() string {
+	switch e.Kind {
+	case ToolNotInstalled:
+		return fmt.Sprintf("%s is not installed or not in PATH", e.Name)
+	case ToolTooOld:
+		return fmt.Sprintf("%s %s is too old, version %s or newer is required", e.Name, e.Version, e.MinVersion)
+	}
+	return fmt.Sprintf("cannot detect version of %s: %v", e.Name, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ToolError) Unwrap() error {
+	return e.Err
+}
+
+// ToolVersionArgs holds arguments used to print version of programs,
+// programs not listed here are run with "--version".
+var ToolVersionArgs = map[string][]string{
+	"go": {"version"},
+}
+
+var toolCache = struct {
+	lock     sync.Mutex
+	paths    map[string]string
+	versions map[string]string // Keyed by path.
+}{
+	paths:    make(map[string]string),
+	versions: make(map[string]string),
+}
+
+// ClearLookPathCache removes all results cached by LookPath and ToolVersion.
+func ClearLookPathCache() {
+	toolCache.lock.Lock()
+	defer toolCache.lock.Unlock()
+	toolCache.paths = make(map[string]string)
+	toolCache.versions = make(map[string]string)
+}
+
+// LookPath searches for an executable named file in the directories named
+// by the PATH environment variable like exec.LookPath, and caches found paths.
+// It returns *ToolError when the program cannot be found.
+func LookPath(file string) (string, error) {
+	toolCache.lock.Lock()
+	p, ok := toolCache.paths[file]
+	toolCache.lock.Unlock()
+	if ok {
+		return p, nil
+	}
+
+	p, err := exec.LookPath(file)
+	if err != nil {
+		return "", &ToolError{Kind: ToolNotInstalled, Name: file, Err: err}
+	}
+
+	toolCache.lock.Lock()
+	toolCache.paths[file] = p
+	toolCache.lock.Unlock()
+	return p, nil
+}
+
+// versionPattern matches versions like "2.39.1" or "1.21" in output of programs.
+var versionPattern = regexp.MustCompile(`(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// ToolVersion returns version of the program by running it with
+// arguments in ToolVersionArgs and parsing the first version number of
+// its output, e.g. "2.39.1" for "git version 2.39.1". Results are cached.
+func ToolVersion(name string) (string, error) {
+	p, err := LookPath(name)
+	if err != nil {
+		return "", err
+	}
+
+	toolCache.lock.Lock()
+	version, ok := toolCache.versions[p]
+	toolCache.lock.Unlock()
+	if ok {
+		return version, nil
+	}
+
+	args, ok := ToolVersionArgs[name]
+	if !ok {
+		args = []string{"--version"}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	r, err := NewCmd(p, args...).RunContext(ctx)
+	if err != nil {
+		return "", &ToolError{Kind: ToolUnknownVersion, Name: name, Path: p, Err: r.CheckSuccess()}
+	}
+
+	// Some programs print version to stderr.
+	version = versionPattern.FindString(string(r.Stdout))
+	if len(version) == 0 {
+		version = versionPattern.FindString(string(r.Stderr))
+	}
+	if len(version) == 0 {
+		return "", &ToolError{Kind: ToolUnknownVersion, Name: name, Path: p,
+			Err: errors.New("no version number in output")}
+	}
+
+	toolCache.lock.Lock()
+	toolCache.versions[p] = version
+	toolCache.lock.Unlock()
+	return version, nil
+}
+
+// compareVersion compares dot-separated numeric versions,
+// missing parts count as zero. It returns -1, 0 or 1.
+func compareVersion(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x, _ = strconv.Atoi(as[i])
+		}
+		if i < len(bs) {
+			y, _ = strconv.Atoi(bs[i])
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+// RequireTool checks that the program is installed and its version is not
+// lower than minVersion, which is not checked if empty.
+// It returns resolved path of the program, or *ToolError otherwise.
+func RequireTool(name, minVersion string) (string, error) {
+	p, err := LookPath(name)
+	if err != nil || len(minVersion) == 0 {
+		return p, err
+	}
+
+	version, err := ToolVersion(name)
+	if err != nil {
+		return "", err
+	}
+	if compareVersion(version, minVersion) < 0 {
+		return "", &ToolError{
+			Kind:       ToolTooOld,
+			Name:       name,
+			Path:       p,
+			Version:    version,
+			MinVersion: minVersion,
+		}
+	}
+	return p, nil
+}
+
 // _________        .__                 .____
 // \_   ___ \  ____ |  |   ___________  |    |    ____   ____
 // /    \  \/ /  _ \|  |  /  _ \_  __ \ |    |   /  _ \ / ___\
@@ -78,21 +1236,55 @@ const (
 	EndColor = "\033[0m"
 )
 
+// LogLevel is the severity of a log message.
+type LogLevel int
+
+// Log levels, in order of increasing severity.
+const (
+	LevelTrace LogLevel = iota
+	LevelDebug
+	LevelInfo
+	LevelSuccess
+	LevelWarn
+	LevelError
+)
+
+// logLevelNames holds short names of levels as used in log lines.
+var logLevelNames = []string{"TRAC", "DEBU", "INFO", "SUCC", "WARN", "ERRO"}
+
+// logLevelColors maps short level names to their colors in ColorLogS,
+// other levels use default color.
+var logLevelColors = map[string]uint8{
+	"TRAC": Blue,
+	"SUCC": Green,
+	"WARN": Magenta,
+	"ERRO": Red,
+}
+
+// loggerLevelColors maps levels to their colors in Logger,
+// other levels use default color.
+var loggerLevelColors = map[LogLevel]uint8{
+	LevelTrace:   Blue,
+	LevelDebug:   Gray,
+	LevelSuccess: Green,
+	LevelWarn:    Magenta,
+	LevelError:   Red,
+}
+
+func (l LogLevel) String() string {
+	if l < LevelTrace || l > LevelError {
+		return fmt.Sprintf("LEVEL(%d)", int(l))
+	}
+	return logLevelNames[l]
+}
+
 // getColorLevel returns colored level string by given level.
 func getColorLevel(level string) string {
 	level = strings.ToUpper(level)
-	switch level {
-	case "TRAC":
-		return fmt.Sprintf("\033[%dm%s\033[0m", Blue, level)
-	case "ERRO":
-		return fmt.Sprintf("\033[%dm%s\033[0m", Red, level)
-	case "WARN":
-		return fmt.Sprintf("\033[%dm%s\033[0m", Magenta, level)
-	case "SUCC":
-		return fmt.Sprintf("\033[%dm%s\033[0m", Green, level)
-	default:
-		return level
+	if color := logLevelColors[level]; color > 0 {
+		return fmt.Sprintf("\033[%dm%s\033[0m", color, level)
 	}
+	return level
 }
 
 // ColorLogS colors log and return colored content.
@@ -103,13 +1295,13 @@ func getColorLevel(level string) string {
 // Highlights have to be surrounded by "# " and " #"(space), "#" will be deleted.
 // Paths have to be surrounded by "( " and " )"(space).
 // Errors have to be surrounded by "[ " and " ]"(space).
-// Note: it hasn't support windows yet, contribute is welcome.
+// Colors are only used if standard output supports them, see StdoutColorLevel.
 func ColorLogS(format string, a ...interface{}) string {
 	log := fmt.Sprintf(format, a...)
 
 	var clog string
 
-	if runtime.GOOS != "windows" {
+	if StdoutColorLevel() > ColorNone {
 		// Level.
 		i := strings.Index(log, "]")
 		if log[0] == '[' && i > -1 {
@@ -159,3 +1351,221 @@ func ColorLogS(format string, a ...interface{}) string {
 func ColorLog(format string, a ...interface{}) {
 	fmt.Print(ColorLogS(format, a...))
 }
+
+// logOutput is a destination of Logger.
+type logOutput struct {
+	w     io.Writer
+	level ColorLevel // Detected when the output was added.
+	color *bool      // Set by SetColor.
+}
+
+// colored returns true if escape codes should be written to the output.
+func (o logOutput) colored() bool {
+	if o.color != nil {
+		return *o.color
+	}
+	if level, ok := overrideColorLevel(); ok {
+		return level > ColorNone
+	}
+	return o.level > ColorNone
+}
+
+// Logger writes leveled log lines with key-value fields to its outputs,
+// using the same colors as ColorLog. It is safe for concurrent use.
+//
+// Log lines have format: [LEVEL] message key=value ...
+type Logger struct {
+	// Shared by loggers derived with With.
+	lock    *sync.Mutex
+	outputs *[]logOutput
+
+	level      LogLevel
+	fields     []interface{}
+	timeFormat string
+}
+
+// NewLogger returns a Logger that writes to given outputs with minimum level Info.
+// Output is colored if it is a terminal that supports colors, see DetectColorLevel.
+func NewLogger(outputs ...io.Writer) *Logger {
+	l := &Logger{
+		lock:    new(sync.Mutex),
+		outputs: new([]logOutput),
+		level:   LevelInfo,
+	}
+	for _, w := range outputs {
+		l.AddOutput(w)
+	}
+	return l
+}
+
+// AddOutput adds an output to the logger and all loggers derived from it.
+func (l *Logger) AddOutput(w io.Writer) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	out := logOutput{w: w}
+	if f, ok := w.(*os.File); ok {
+		if f == os.Stdout {
+			out.level = StdoutColorLevel()
+		} else {
+			out.level = DetectColorLevel(f)
+		}
+	}
+	*l.outputs = append(*l.outputs, out)
+}
+
+// SetColor enables or disables colored output for all current outputs,
+// regardless of detected color support and SetColorLevel.
+func (l *Logger) SetColor(color bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for i := range *l.outputs {
+		(*l.outputs)[i].color = &color
+	}
+}
+
+// SetLevel sets the minimum level of messages to be written.
+func (l *Logger) SetLevel(level LogLevel) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.level = level
+}
+
+// Level returns the minimum level of messages to be written.
+func (l *Logger) Level() LogLevel {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.level
+}
+
+// SetTimeFormat makes log lines start with current time in given layout,
+// empty layout disables it.
+func (l *Logger) SetTimeFormat(layout string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.timeFormat = layout
+}
+
+// With returns a logger that adds given key-value pairs to every message,
+// it shares outputs with the original logger.
+func (l *Logger) With(keyvals ...interface{}) *Logger {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	nl := *l
+	nl.fields = append(append(make([]interface{}, 0, len(l.fields)+len(keyvals)), l.fields...), keyvals...)
+	return &nl
+}
+
+// Log writes message with key-value pairs if level is not below the minimum level.
+func (l *Logger) Log(level LogLevel, msg string, keyvals ...interface{}) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if level < l.level {
+		return
+	}
+
+	var t time.Time
+	if len(l.timeFormat) > 0 {
+		t = time.Now()
+	}
+
+	// Format at most once for plain outputs and once for colored ones.
+	var lines [2]string
+	for _, out := range *l.outputs {
+		i, color := 0, out.colored()
+		if color {
+			i = 1
+		}
+		if len(lines[i]) == 0 {
+			lines[i] = l.format(t, level, msg, keyvals, color)
+		}
+		io.WriteString(out.w, lines[i])
+	}
+}
+
+// Trace writes message at trace level.
+func (l *Logger) Trace(msg string, keyvals ...interface{}) {
+	l.Log(LevelTrace, msg, keyvals...)
+}
+
+// Debug writes message at debug level.
+func (l *Logger) Debug(msg string, keyvals ...interface{}) {
+	l.Log(LevelDebug, msg, keyvals...)
+}
+
+// Info writes message at info level.
+func (l *Logger) Info(msg string, keyvals ...interface{}) {
+	l.Log(LevelInfo, msg, keyvals...)
+}
+
+// Success writes message at success level.
+func (l *Logger) Success(msg string, keyvals ...interface{}) {
+	l.Log(LevelSuccess, msg, keyvals...)
+}
+
+// Warn writes message at warning level.
+func (l *Logger) Warn(msg string, keyvals ...interface{}) {
+	l.Log(LevelWarn, msg, keyvals...)
+}
+
+// Error writes message at error level.
+func (l *Logger) Error(msg string, keyvals ...interface{}) {
+	l.Log(LevelError, msg, keyvals...)
+}
+
+// format returns a complete log line.
+func (l *Logger) format(t time.Time, level LogLevel, msg string, keyvals []interface{}, color bool) string {
+	buf := new(bytes.Buffer)
+	if !t.IsZero() {
+		buf.WriteString(t.Format(l.timeFormat))
+		buf.WriteByte(' ')
+	}
+
+	name := level.String()
+	if c := loggerLevelColors[level]; color && c > 0 {
+		name = fmt.Sprintf("\033[%dm%s\033[0m", c, name)
+	}
+	buf.WriteString("[" + name + "] " + msg)
+
+	l.formatFields(buf, l.fields, color)
+	l.formatFields(buf, keyvals, color)
+	buf.WriteByte('\n')
+	return buf.String()
+}
+
+// formatFields writes key-value pairs, a key without value gets "!MISSING".
+func (l *Logger) formatFields(buf *bytes.Buffer, keyvals []interface{}, color bool) {
+	for i := 0; i < len(keyvals); i += 2 {
+		key := fmt.Sprint(keyvals[i])
+		val, isErr := "!MISSING", false
+		if i+1 < len(keyvals) {
+			val = formatLogValue(keyvals[i+1])
+			_, isErr = keyvals[i+1].(error)
+		}
+
+		buf.WriteByte(' ')
+		if !color {
+			buf.WriteString(key + "=" + val)
+			continue
+		}
+
+		// Keys are gray and errors are red, like highlights and errors of ColorLog.
+		fmt.Fprintf(buf, "\033[%dm%s=%s", Gray, key, EndColor)
+		if isErr {
+			fmt.Fprintf(buf, "\033[%dm%s%s", Red, val, EndColor)
+		} else {
+			buf.WriteString(val)
+		}
+	}
+}
+
+// formatLogValue returns string form of a field value,
+// quoted if it is empty or contains spaces, quotes or equal signs.
+func formatLogValue(v interface{}) string {
+	s := fmt.Sprint(v)
+	if len(s) == 0 || strings.ContainsAny(s, " \t\r\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -15,101 +15,169 @@
 package com
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestColorLogS(t *testing.T) {
-	if runtime.GOOS != "windows" {
-		// Trace + path.
-		cls := ColorLogS("[TRAC] Trace level test with path( %s )", "/path/to/somethere")
-		clsR := fmt.Sprintf(
-			"[\033[%dmTRAC%s] Trace level test with path(\033[%dm%s%s)",
-			Blue, EndColor, Yellow, "/path/to/somethere", EndColor)
-		if cls != clsR {
-			t.Errorf("ColorLogS:\n Expect => %s\n Got => %s\n", clsR, cls)
-		}
+	defer ResetColorLevel()
 
-		// Error + error.
-		cls = ColorLogS("[ERRO] Error level test with error[ %s ]", "test error")
-		clsR = fmt.Sprintf(
-			"[\033[%dmERRO%s] Error level test with error[\033[%dm%s%s]",
-			Red, EndColor, Red, "test error", EndColor)
-		if cls != clsR {
-			t.Errorf("ColorLogS:\n Expect => %s\n Got => %s\n", clsR, cls)
-		}
+	for _, level := range []ColorLevel{Color16, ColorNone} {
+		SetColorLevel(level)
+		if level > ColorNone {
+			// Trace + path.
+			cls := ColorLogS("[TRAC] Trace level test with path( %s )", "/path/to/somethere")
+			clsR := fmt.Sprintf(
+				"[\033[%dmTRAC%s] Trace level test with path(\033[%dm%s%s)",
+				Blue, EndColor, Yellow, "/path/to/somethere", EndColor)
+			if cls != clsR {
+				t.Errorf("ColorLogS:\n Expect => %s\n Got => %s\n", clsR, cls)
+			}
 
-		// Warning + highlight.
-		cls = ColorLogS("[WARN] Warnning level test with highlight # %s #", "special offer!")
-		clsR = fmt.Sprintf(
-			"[\033[%dmWARN%s] Warnning level test with highlight \033[%dm%s%s",
-			Magenta, EndColor, Gray, "special offer!", EndColor)
-		if cls != clsR {
-			t.Errorf("ColorLogS:\n Expect => %s\n Got => %s\n", clsR, cls)
-		}
+			// Error + error.
+			cls = ColorLogS("[ERRO] Error level test with error[ %s ]", "test error")
+			clsR = fmt.Sprintf(
+				"[\033[%dmERRO%s] Error level test with error[\033[%dm%s%s]",
+				Red, EndColor, Red, "test error", EndColor)
+			if cls != clsR {
+				t.Errorf("ColorLogS:\n Expect => %s\n Got => %s\n", clsR, cls)
+			}
 
-		// Success.
-		cls = ColorLogS("[SUCC] Success level test")
-		clsR = fmt.Sprintf(
-			"[\033[%dmSUCC%s] Success level test",
-			Green, EndColor)
-		if cls != clsR {
-			t.Errorf("ColorLogS:\n Expect => %s\n Got => %s\n", clsR, cls)
-		}
+			// Warning + highlight.
+			cls = ColorLogS("[WARN] Warnning level test with highlight # %s #", "special offer!")
+			clsR = fmt.Sprintf(
+				"[\033[%dmWARN%s] Warnning level test with highlight \033[%dm%s%s",
+				Magenta, EndColor, Gray, "special offer!", EndColor)
+			if cls != clsR {
+				t.Errorf("ColorLogS:\n Expect => %s\n Got => %s\n", clsR, cls)
+			}
 
-		// Default.
-		cls = ColorLogS("[INFO] Default level test")
-		clsR = fmt.Sprintf(
-			"[INFO] Default level test")
-		if cls != clsR {
-			t.Errorf("ColorLogS:\n Expect => %s\n Got => %s\n", clsR, cls)
-		}
-	} else {
-		// Trace + path.
-		cls := ColorLogS("[TRAC] Trace level test with path( %s )", "/path/to/somethere")
-		clsR := fmt.Sprintf(
-			"[TRAC] Trace level test with path(%s)",
-			"/path/to/somethere")
-		if cls != clsR {
-			t.Errorf("ColorLogS:\n Expect => %s\n Got => %s\n", clsR, cls)
-		}
+			// Success.
+			cls = ColorLogS("[SUCC] Success level test")
+			clsR = fmt.Sprintf(
+				"[\033[%dmSUCC%s] Success level test",
+				Green, EndColor)
+			if cls != clsR {
+				t.Errorf("ColorLogS:\n Expect => %s\n Got => %s\n", clsR, cls)
+			}
 
-		// Error + error.
-		cls = ColorLogS("[ERRO] Error level test with error[ %s ]", "test error")
-		clsR = fmt.Sprintf(
-			"[ERRO] Error level test with error[%s]",
-			"test error")
-		if cls != clsR {
-			t.Errorf("ColorLogS:\n Expect => %s\n Got => %s\n", clsR, cls)
-		}
+			// Default.
+			cls = ColorLogS("[INFO] Default level test")
+			clsR = fmt.Sprintf(
+				"[INFO] Default level test")
+			if cls != clsR {
+				t.Errorf("ColorLogS:\n Expect => %s\n Got => %s\n", clsR, cls)
+			}
+		} else {
+			// Trace + path.
+			cls := ColorLogS("[TRAC] Trace level test with path( %s )", "/path/to/somethere")
+			clsR := fmt.Sprintf(
+				"[TRAC] Trace level test with path(%s)",
+				"/path/to/somethere")
+			if cls != clsR {
+				t.Errorf("ColorLogS:\n Expect => %s\n Got => %s\n", clsR, cls)
+			}
 
-		// Warning + highlight.
-		cls = ColorLogS("[WARN] Warnning level test with highlight # %s #", "special offer!")
-		clsR = fmt.Sprintf(
-			"[WARN] Warnning level test with highlight %s",
-			"special offer!")
-		if cls != clsR {
-			t.Errorf("ColorLogS:\n Expect => %s\n Got => %s\n", clsR, cls)
-		}
+			// Error + error.
+			cls = ColorLogS("[ERRO] Error level test with error[ %s ]", "test error")
+			clsR = fmt.Sprintf(
+				"[ERRO] Error level test with error[%s]",
+				"test error")
+			if cls != clsR {
+				t.Errorf("ColorLogS:\n Expect => %s\n Got => %s\n", clsR, cls)
+			}
 
-		// Success.
-		cls = ColorLogS("[SUCC] Success level test")
-		clsR = fmt.Sprintf(
-			"[SUCC] Success level test")
-		if cls != clsR {
-			t.Errorf("ColorLogS:\n Expect => %s\n Got => %s\n", clsR, cls)
-		}
+			// Warning + highlight.
+			cls = ColorLogS("[WARN] Warnning level test with highlight # %s #", "special offer!")
+			clsR = fmt.Sprintf(
+				"[WARN] Warnning level test with highlight %s",
+				"special offer!")
+			if cls != clsR {
+				t.Errorf("ColorLogS:\n Expect => %s\n Got => %s\n", clsR, cls)
+			}
+
+			// Success.
+			cls = ColorLogS("[SUCC] Success level test")
+			clsR = fmt.Sprintf(
+				"[SUCC] Success level test")
+			if cls != clsR {
+				t.Errorf("ColorLogS:\n Expect => %s\n Got => %s\n", clsR, cls)
+			}
 
-		// Default.
-		cls = ColorLogS("[INFO] Default level test")
-		clsR = fmt.Sprintf(
-			"[INFO] Default level test")
-		if cls != clsR {
-			t.Errorf("ColorLogS:\n Expect => %s\n Got => %s\n", clsR, cls)
+			// Default.
+			cls = ColorLogS("[INFO] Default level test")
+			clsR = fmt.Sprintf(
+				"[INFO] Default level test")
+			if cls != clsR {
+				t.Errorf("ColorLogS:\n Expect => %s\n Got => %s\n", clsR, cls)
+			}
 		}
 	}
+
+}
+
+func TestLogger(t *testing.T) {
+	defer ResetColorLevel()
+
+	plain := new(bytes.Buffer)
+	colored := new(bytes.Buffer)
+	l := NewLogger(plain)
+	l.AddOutput(colored)
+	l.SetColor(false)
+	SetColorLevel(Color256)
+	l.AddOutput(colored)
+
+	l.Debug("hidden")
+	l.With("repo", "com").Info("cloned", "path", "/tmp/com dir", "took")
+	l.Error("failed", "err", errors.New("boom"))
+	l.SetLevel(LevelTrace)
+	l.Trace("shown")
+
+	expect := "[INFO] cloned repo=com path=\"/tmp/com dir\" took=!MISSING\n" +
+		"[ERRO] failed err=boom\n" +
+		"[TRAC] shown\n"
+	if plain.String() != expect {
+		t.Errorf("Logger:\n Expect => %s\n Got => %s\n", expect, plain)
+	}
+
+	// Lines are written plain by the first output and colored by the second one.
+	expect = fmt.Sprintf("[ERRO] failed err=boom\n[\033[%dmERRO%s] failed \033[%dmerr=%s\033[%dmboom%s\n",
+		Red, EndColor, Gray, EndColor, Red, EndColor)
+	if lines := strings.SplitAfter(colored.String(), "\n"); len(lines) != 7 || lines[2]+lines[3] != expect {
+		t.Errorf("Logger:\n Expect => %q\n Got => %q\n", expect, colored)
+	}
+
+	// Debug level is gray in Logger only, ColorLogS keeps legacy palette.
+	colored.Reset()
+	l.Debug("debug")
+	expect = fmt.Sprintf("[DEBU] debug\n[\033[%dmDEBU%s] debug\n", Gray, EndColor)
+	if colored.String() != expect {
+		t.Errorf("Logger:\n Expect => %q\n Got => %q\n", expect, colored)
+	}
+	if cls := ColorLogS("[DEBU] debug"); cls != "[DEBU] debug" {
+		t.Errorf("ColorLogS:\n Expect => %q\n Got => %q\n", "[DEBU] debug", cls)
+	}
+
+	// Buffers are not terminals.
+	colored.Reset()
+	ResetColorLevel()
+	NewLogger(colored).Info("plain")
+	if colored.String() != "[INFO] plain\n" {
+		t.Errorf("Logger:\n Expect => %q\n Got => %q\n", "[INFO] plain\n", colored)
+	}
+
+	if LevelWarn.String() != "WARN" || LogLevel(42).String() != "LEVEL(42)" {
+		t.Errorf("LogLevel.String:\n Expect => %s\n Got => %s\n", "WARN", LevelWarn)
+	}
 }
 
 func TestExecCmd(t *testing.T) {
@@ -123,6 +191,403 @@ func TestExecCmd(t *testing.T) {
 	}
 }
 
+func TestExecCmdContext(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	// Non-zero exit status.
+	_, _, err := ExecCmdContext(context.Background(), "sh", "-c", "exit 3")
+	if e, ok := err.(*ExecError); !ok || e.Kind != ExecExited || e.ExitCode() != 3 {
+		t.Errorf("ExecCmdContext:\n Expect => %s\n Got => %v\n", "exit status 3", err)
+	}
+
+	// Timeout must also kill children holding the output pipes.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, _, err = ExecCmdContext(ctx, "sh", "-c", "sleep 10 & sleep 10; wait")
+	if e, ok := err.(*ExecError); !ok || !e.Timeout() {
+		t.Errorf("ExecCmdContext:\n Expect => %s\n Got => %v\n", ExecTimedOut, err)
+	} else if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("ExecCmdContext:\n Expect => %s\n Got => %s\n", "killed promptly", d)
+	}
+
+	// Cancellation.
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	_, _, err = ExecCmdContext(ctx, "sleep", "10")
+	if e, ok := err.(*ExecError); !ok || !e.Canceled() {
+		t.Errorf("ExecCmdContext:\n Expect => %s\n Got => %v\n", ExecCanceled, err)
+	}
+
+	stdout, _, err := ExecCmdDirContext(context.Background(), "testdata", "ls")
+	if err != nil || !strings.Contains(stdout, "statDir") {
+		t.Errorf("ExecCmdDirContext:\n Expect => %s\n Got => %s, %v\n", "statDir", stdout, err)
+	}
+}
+
+func TestCmdRun(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	r, err := NewCmd("sh", "-c", "echo out; echo err >&2").Run()
+	if err != nil {
+		t.Fatalf("Cmd.Run:\n Expect => %v\n Got => %v\n", nil, err)
+	}
+	if !r.Success() || r.ExitCode != 0 || string(r.Stdout) != "out\n" || string(r.Stderr) != "err\n" {
+		t.Errorf("Cmd.Run:\n Expect => %s\n Got => %+v\n", "success with output", r)
+	}
+	if !strings.HasSuffix(r.Argv[0], "sh") || len(r.Argv) != 3 || r.Duration <= 0 {
+		t.Errorf("Cmd.Run:\n Expect => %s\n Got => %v, %s\n", "resolved argv", r.Argv, r.Duration)
+	}
+	if err = r.CheckSuccess(); err != nil {
+		t.Errorf("Result.CheckSuccess:\n Expect => %v\n Got => %v\n", nil, err)
+	}
+
+	r, _ = NewCmd("sh", "-c", "echo first >&2; echo 'fatal: bad thing' >&2; exit 2").Run()
+	if r.ExitCode != 2 || r.Signal != nil {
+		t.Errorf("Cmd.Run:\n Expect => %d\n Got => %d, %v\n", 2, r.ExitCode, r.Signal)
+	}
+	err = r.CheckSuccess()
+	if e, ok := err.(*ExecError); !ok || e.ExitCode() != 2 ||
+		err.Error() != "sh: exit status 2: first\nfatal: bad thing" {
+		t.Errorf("Result.CheckSuccess:\n Expect => %s\n Got => %v\n", "exit status 2 with stderr", err)
+	}
+
+	r, _ = NewCmd("sh", "-c", "kill -TERM $$").Run()
+	if r.ExitCode != -1 || r.Signal == nil || r.Signal.String() != "terminated" {
+		t.Errorf("Cmd.Run:\n Expect => %s\n Got => %d, %v\n", "terminated", r.ExitCode, r.Signal)
+	}
+
+	r, err = NewCmd("com-no-such-command").Run()
+	if err == nil || r.ExitCode != -1 || r.CheckSuccess() != err {
+		t.Errorf("Cmd.Run:\n Expect => %s\n Got => %v\n", "start error", err)
+	}
+}
+
+func TestCmdStream(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	var lines, errLines []string
+	all := new(bytes.Buffer)
+	c := NewCmd("sh", "-c", "for i in 1 2 3 4 5; do echo line$i; done; echo oops >&2; printf tail")
+	c.Stdout = all
+	c.Stderr = all
+	c.StdoutLine = func(line string) { lines = append(lines, line) }
+	c.StderrLine = func(line string) { errLines = append(errLines, line) }
+	c.MaxOutput = 10
+	r, err := c.Run()
+	if err != nil {
+		t.Fatalf("Cmd.Run:\n Expect => %v\n Got => %v\n", nil, err)
+	}
+
+	expect := []string{"line1", "line2", "line3", "line4", "line5", "tail"}
+	if strings.Join(lines, ",") != strings.Join(expect, ",") {
+		t.Errorf("Cmd.StdoutLine:\n Expect => %v\n Got => %v\n", expect, lines)
+	}
+	if len(errLines) != 1 || errLines[0] != "oops" {
+		t.Errorf("Cmd.StderrLine:\n Expect => %v\n Got => %v\n", []string{"oops"}, errLines)
+	}
+	if string(r.Stdout) != "line5\ntail" {
+		t.Errorf("Cmd.MaxOutput:\n Expect => %q\n Got => %q\n", "line5\ntail", r.Stdout)
+	}
+	if all.Len() != len("line1\n")*5+len("oops\ntail") {
+		t.Errorf("Cmd.Stdout:\n Expect => %d\n Got => %d\n", 44, all.Len())
+	}
+}
+
+func TestCmdStreamSharedLine(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	// One handler of both streams must not race, run with -race to check.
+	count := 0
+	handle := func(line string) { count++ }
+	c := NewCmd("sh", "-c", "i=0; while [ $i -lt 200 ]; do echo out; echo err >&2; i=$((i+1)); done")
+	c.StdoutLine = handle
+	c.StderrLine = handle
+	if _, err := c.Run(); err != nil {
+		t.Fatalf("Cmd.Run:\n Expect => %v\n Got => %v\n", nil, err)
+	}
+	if count != 400 {
+		t.Errorf("Cmd.StdoutLine and Cmd.StderrLine:\n Expect => %d\n Got => %d\n", 400, count)
+	}
+}
+
+func TestCmdEnv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	os.Setenv("COM_TEST_INHERITED", "yes")
+	os.Setenv("COM_TEST_REMOVED", "yes")
+	defer os.Unsetenv("COM_TEST_INHERITED")
+	defer os.Unsetenv("COM_TEST_REMOVED")
+
+	script := "echo $COM_TEST_INHERITED,$COM_TEST_REMOVED,$COM_TEST_ADDED,$PATH"
+	c := NewCmd("sh", "-c", script)
+	c.Env = []string{"COM_TEST_ADDED=1", "COM_TEST_ADDED=2"}
+	c.Unsetenv = []string{"COM_TEST_REMOVED"}
+	r, err := c.Run()
+	expect := "yes,,2," + os.Getenv("PATH") + "\n"
+	if err != nil || string(r.Stdout) != expect {
+		t.Errorf("Cmd.Env:\n Expect => %s\n Got => %s, %v\n", expect, r.Stdout, err)
+	}
+
+	c.CleanEnv = true
+	r, err = c.Run()
+	expect = ",,2," + os.Getenv("PATH") + "\n"
+	if err != nil || string(r.Stdout) != expect {
+		t.Errorf("Cmd.CleanEnv:\n Expect => %s\n Got => %s, %v\n", expect, r.Stdout, err)
+	}
+
+	c = NewCmd("cat")
+	c.StdinBytes = []byte("from bytes")
+	if r, err = c.Run(); err != nil || string(r.Stdout) != "from bytes" {
+		t.Errorf("Cmd.StdinBytes:\n Expect => %s\n Got => %s, %v\n", "from bytes", r.Stdout, err)
+	}
+	c.Stdin = strings.NewReader("from reader")
+	if r, err = c.Run(); err != nil || string(r.Stdout) != "from reader" {
+		t.Errorf("Cmd.Stdin:\n Expect => %s\n Got => %s, %v\n", "from reader", r.Stdout, err)
+	}
+}
+
+func TestMergeEnv(t *testing.T) {
+	env := MergeEnv(
+		[]string{"A=1", "B=2", "=C:=C:\\", "D=4"},
+		[]string{"B=3", "E=5", "A=6"},
+		[]string{"D", "=C:"})
+	expect := "A=6 B=3 E=5"
+	if strings.Join(env, " ") != expect {
+		t.Errorf("MergeEnv:\n Expect => %s\n Got => %s\n", expect, env)
+	}
+}
+
+func TestRingBuffer(t *testing.T) {
+	r := NewRingBuffer(4)
+	r.Write([]byte("ab"))
+	if string(r.Bytes()) != "ab" || r.Truncated() {
+		t.Errorf("RingBuffer:\n Expect => %s\n Got => %s\n", "ab", r.Bytes())
+	}
+	r.Write([]byte("cde"))
+	if string(r.Bytes()) != "bcde" || !r.Truncated() || r.Written() != 5 {
+		t.Errorf("RingBuffer:\n Expect => %s\n Got => %s\n", "bcde", r.Bytes())
+	}
+	r.Write([]byte("0123456789"))
+	if string(r.Bytes()) != "6789" {
+		t.Errorf("RingBuffer:\n Expect => %s\n Got => %s\n", "6789", r.Bytes())
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	input := NewCmd("sh", "-c", "echo $COM_TEST_WORD; echo bar; echo foo")
+	input.Env = []string{"COM_TEST_WORD=food"}
+	p := NewPipeline(input, NewCmd("grep", "foo"), NewCmd("sort"))
+	p.Env = []string{"COM_TEST_WORD=fool", "LC_ALL=C"}
+	r, err := p.Run()
+	if err != nil || string(r.Stdout()) != "foo\nfood\n" || r.ExitCode() != 0 || len(r.Stages) != 3 {
+		t.Errorf("Pipeline.Run:\n Expect => %q\n Got => %q, %v\n", "foo\nfood\n", r.Stdout(), err)
+	}
+
+	// Pipefail: status of the last failing command is reported.
+	r, err = NewPipeline(
+		NewCmd("sh", "-c", "echo broken >&2; exit 3"),
+		NewCmd("cat"),
+		NewCmd("sh", "-c", "cat; exit 0"),
+	).Run()
+	if e, ok := err.(*ExecError); !ok || e.ExitCode() != 3 || r.ExitCode() != 3 {
+		t.Errorf("Pipeline.Run:\n Expect => %s\n Got => %v\n", "exit status 3", err)
+	}
+	if err = r.CheckSuccess(); err == nil || !strings.HasSuffix(err.Error(), "broken") {
+		t.Errorf("PipelineResult.CheckSuccess:\n Expect => %s\n Got => %v\n", "broken", err)
+	}
+
+	p = NewPipeline(NewCmd("ls"), NewCmd("grep", "statDir"))
+	p.Dir = "testdata"
+	if r, err = p.Run(); err != nil || string(r.Stdout()) != "statDir\n" {
+		t.Errorf("Pipeline.Dir:\n Expect => %s\n Got => %s, %v\n", "statDir", r.Stdout(), err)
+	}
+
+	if _, err = NewPipeline(NewCmd("true"), NewCmd("com-no-such-command")).Run(); err == nil {
+		t.Errorf("Pipeline.Run:\n Expect => %s\n Got => %v\n", "start error", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = NewPipeline(NewCmd("sleep", "10"), NewCmd("cat")).RunContext(ctx)
+	if e, ok := err.(*ExecError); !ok || !e.Timeout() {
+		t.Errorf("Pipeline.RunContext:\n Expect => %s\n Got => %v\n", ExecTimedOut, err)
+	}
+}
+
+func TestCmdRunner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	cmds := make([]*Cmd, 4)
+	for i := range cmds {
+		cmds[i] = NewCmd("sh", "-c", fmt.Sprintf("sleep 0.2; echo %d", i))
+	}
+	start := time.Now()
+	results, err := (&CmdRunner{Parallel: 2}).Run(cmds...)
+	if err != nil {
+		t.Fatalf("CmdRunner.Run:\n Expect => %v\n Got => %v\n", nil, err)
+	}
+	if d := time.Since(start); d < 400*time.Millisecond {
+		t.Errorf("CmdRunner.Parallel:\n Expect => %s\n Got => %s\n", ">= 400ms", d)
+	}
+	for i, r := range results {
+		if string(r.Stdout) != fmt.Sprintf("%d\n", i) {
+			t.Errorf("CmdRunner.Run:\n Expect => %d\n Got => %s\n", i, r.Stdout)
+		}
+	}
+
+	// Succeeds on the second attempt.
+	flag := path.Join(t.TempDir(), "flag")
+	flaky := NewCmd("sh", "-c", "[ -f "+flag+" ] && exit 0; touch "+flag+"; exit 1")
+	broken := NewCmd("sh", "-c", "echo nope >&2; exit 2")
+	broken.Retry = &RetryPolicy{Attempts: 1}
+	runner := &CmdRunner{Retry: &RetryPolicy{Attempts: 3, Backoff: time.Millisecond}}
+	results, err = runner.Run(flaky, broken)
+	if results[0].Attempts != 2 || !results[0].Success() {
+		t.Errorf("CmdRunner.Retry:\n Expect => %d\n Got => %d\n", 2, results[0].Attempts)
+	}
+	if results[1].Attempts != 1 {
+		t.Errorf("Cmd.Retry:\n Expect => %d\n Got => %d\n", 1, results[1].Attempts)
+	}
+	if e, ok := err.(MultiError); !ok || len(e) != 1 || err.Error() != "sh: exit status 2: nope" {
+		t.Errorf("CmdRunner.Run:\n Expect => %s\n Got => %v\n", "sh: exit status 2: nope", err)
+	}
+
+	results, err = (&CmdRunner{Parallel: 1, StopOnError: true}).Run(
+		NewCmd("false"), NewCmd("true"), NewCmd("true"))
+	if err == nil || results[0] == nil || results[1] != nil || results[2] != nil {
+		t.Errorf("CmdRunner.StopOnError:\n Expect => %s\n Got => %v, %v\n", "skipped", results, err)
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := &RetryPolicy{Backoff: time.Second, Multiplier: 2, MaxBackoff: 5 * time.Second}
+	for attempt, expect := range []time.Duration{0, 1, 2, 4, 5, 5} {
+		if attempt == 0 {
+			continue
+		}
+		if d := p.delay(attempt); d != expect*time.Second {
+			t.Errorf("RetryPolicy.delay(%d):\n Expect => %s\n Got => %s\n", attempt, expect*time.Second, d)
+		}
+	}
+}
+
+func TestLookPath(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+	ClearLookPathCache()
+	defer ClearLookPathCache()
+
+	// Fake tools print version to stderr or nothing at all.
+	dir := t.TempDir()
+	tools := map[string]string{
+		"com-fake-tool":      "#!/bin/sh\necho \"com-fake-tool version 1.4.2\" >&2\n",
+		"com-fake-noversion": "#!/bin/sh\necho hello\n",
+	}
+	for name, script := range tools {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", dir)
+
+	p, err := LookPath("com-fake-tool")
+	if err != nil || p != filepath.Join(dir, "com-fake-tool") {
+		t.Fatalf("LookPath:\n Expect => %s\n Got => %s, %v\n", filepath.Join(dir, "com-fake-tool"), p, err)
+	}
+	if cached, _ := LookPath("com-fake-tool"); cached != p {
+		t.Errorf("LookPath:\n Expect => %s\n Got => %s\n", p, cached)
+	}
+
+	_, err = LookPath("com-no-such-command")
+	if e, ok := err.(*ToolError); !ok || e.Kind != ToolNotInstalled {
+		t.Errorf("LookPath:\n Expect => %s\n Got => %v\n", "not installed", err)
+	}
+	_, err = NewCmd("com-no-such-command").Run()
+	if e, ok := err.(*ToolError); !ok || e.Kind != ToolNotInstalled {
+		t.Errorf("Cmd.Run:\n Expect => %s\n Got => %v\n", "not installed", err)
+	}
+
+	version, err := ToolVersion("com-fake-tool")
+	if err != nil || version != "1.4.2" {
+		t.Errorf("ToolVersion:\n Expect => %s\n Got => %s, %v\n", "1.4.2", version, err)
+	}
+	_, err = ToolVersion("com-fake-noversion")
+	if e, ok := err.(*ToolError); !ok || e.Kind != ToolUnknownVersion {
+		t.Errorf("ToolVersion:\n Expect => %s\n Got => %v\n", "unknown version", err)
+	}
+
+	if _, err = RequireTool("com-fake-tool", "1.4"); err != nil {
+		t.Errorf("RequireTool:\n Expect => %v\n Got => %v\n", nil, err)
+	}
+	_, err = RequireTool("com-fake-tool", "1.10")
+	if e, ok := err.(*ToolError); !ok || e.Kind != ToolTooOld || e.Version != "1.4.2" {
+		t.Errorf("RequireTool:\n Expect => %s\n Got => %v\n", "too old", err)
+	}
+}
+
+func TestCompareVersion(t *testing.T) {
+	cases := []struct {
+		a, b   string
+		expect int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"1.2", "1.2.0", 0},
+		{"1.10", "1.9", 1},
+		{"2.0.1", "2.1", -1},
+	}
+	for _, c := range cases {
+		if v := compareVersion(c.a, c.b); v != c.expect {
+			t.Errorf("compareVersion(%s, %s):\n Expect => %d\n Got => %d\n", c.a, c.b, c.expect, v)
+		}
+	}
+}
+
+func TestParseCmd(t *testing.T) {
+	c, err := ParseCmd(`git log --format="%h $USER" -n 1`, map[string]string{"USER": "unknwon"})
+	if err != nil || c.Name != "git" || strings.Join(c.Args, "|") != "log|--format=%h unknwon|-n|1" {
+		t.Errorf("ParseCmd:\n Expect => %s\n Got => %v, %v\n", "git with args", c, err)
+	}
+	if s := c.String(); s != "git log '--format=%h unknwon' -n 1" {
+		t.Errorf("Cmd.String:\n Expect => %s\n Got => %s\n", "git log '--format=%h unknwon' -n 1", s)
+	}
+	if _, err = ParseCmd("  # nothing", nil); err == nil {
+		t.Errorf("ParseCmd:\n Expect => %s\n Got => %v\n", "error", err)
+	}
+
+	stdout, _, err := ExecCmdString(`go help "get"`)
+	if err != nil || !strings.HasPrefix(stdout, "usage: go get") {
+		t.Errorf("ExecCmdString:\n Expect => %s\n Got => %s, %v\n", "usage: go get", stdout, err)
+	}
+	if _, _, err = ExecCmdString("go help | grep get"); err == nil {
+		t.Errorf("ExecCmdString:\n Expect => %s\n Got => %v\n", "error", err)
+	}
+}
+
+func TestStderrTail(t *testing.T) {
+	tail := stderrTail([]byte("line one\nline two\nline three\n"), 15)
+	if tail != "...line three" {
+		t.Errorf("stderrTail:\n Expect => %s\n Got => %s\n", "...line three", tail)
+	}
+}
+
 func BenchmarkColorLogS(b *testing.B) {
 	log := fmt.Sprintf(
 		"[WARN] This is a tesing log that should be colored, path( %s ),"+
@@ -0,0 +1,609 @@
+// Copyright 2013 com authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package com
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ColorLevel describes which colors an output supports.
+type ColorLevel int
+
+const (
+	// ColorNone means no escape codes should be written.
+	ColorNone ColorLevel = iota
+	// Color16 means basic ANSI colors are supported.
+	Color16
+	// Color256 means 256-color palette is supported.
+	Color256
+	// ColorTrueColor means 24-bit RGB colors are supported.
+	ColorTrueColor
+)
+
+func (l ColorLevel) String() string {
+	switch l {
+	case ColorNone:
+		return "none"
+	case Color16:
+		return "16"
+	case Color256:
+		return "256"
+	case ColorTrueColor:
+		return "truecolor"
+	}
+	return "unknown"
+}
+
+// IsTerminal returns true if given file is a terminal.
+func IsTerminal(f *os.File) bool {
+	if f == nil {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// DetectColorLevel returns colors supported by given file based on
+// whether it is a terminal and environment variables:
+//
+//	FORCE_COLOR: 0 or false disables colors, 2 and 3 force at least
+//	             256 and true colors, other values force 16 colors.
+//	NO_COLOR:    any non-empty value disables colors.
+//	TERM:        "dumb" disables colors, values containing "256color"
+//	             mean 256 colors.
+//	COLORTERM:   "truecolor" or "24bit" mean true colors.
+//
+// FORCE_COLOR takes precedence over all other rules.
+func DetectColorLevel(f *os.File) ColorLevel {
+	return detectColorLevel(os.LookupEnv, func() bool {
+		return IsTerminal(f) && enableColorTerminal(f)
+	})
+}
+
+// detectColorLevel implements DetectColorLevel, isTerminal is only called when needed.
+func detectColorLevel(lookupEnv func(string) (string, bool), isTerminal func() bool) ColorLevel {
+	getenv := func(key string) string {
+		v, _ := lookupEnv(key)
+		return strings.ToLower(v)
+	}
+
+	level := Color16
+	term := getenv("TERM")
+	switch colorTerm := getenv("COLORTERM"); {
+	case colorTerm == "truecolor" || colorTerm == "24bit":
+		level = ColorTrueColor
+	case strings.Contains(term, "256color"):
+		level = Color256
+	}
+
+	if force, ok := lookupEnv("FORCE_COLOR"); ok {
+		min := Color16
+		switch strings.ToLower(force) {
+		case "0", "false":
+			return ColorNone
+		case "2":
+			min = Color256
+		case "3":
+			min = ColorTrueColor
+		}
+		if level < min {
+			level = min
+		}
+		return level
+	}
+
+	if len(getenv("NO_COLOR")) > 0 || term == "dumb" || !isTerminal() {
+		return ColorNone
+	}
+	return level
+}
+
+var colorState struct {
+	lock     sync.Mutex
+	override *ColorLevel
+	stdout   *ColorLevel
+}
+
+// SetColorLevel overrides detected color support for ColorLog,
+// ColorLogS and Logger outputs.
+func SetColorLevel(level ColorLevel) {
+	colorState.lock.Lock()
+	defer colorState.lock.Unlock()
+	colorState.override = &level
+}
+
+// ResetColorLevel removes the override of SetColorLevel
+// and makes color support be detected again.
+func ResetColorLevel() {
+	colorState.lock.Lock()
+	defer colorState.lock.Unlock()
+	colorState.override = nil
+	colorState.stdout = nil
+}
+
+// overrideColorLevel returns level set by SetColorLevel, if any.
+func overrideColorLevel() (ColorLevel, bool) {
+	colorState.lock.Lock()
+	defer colorState.lock.Unlock()
+	if colorState.override == nil {
+		return ColorNone, false
+	}
+	return *colorState.override, true
+}
+
+// StdoutColorLevel returns colors supported by standard output,
+// which is the level set by SetColorLevel if any, or detected once otherwise.
+func StdoutColorLevel() ColorLevel {
+	colorState.lock.Lock()
+	defer colorState.lock.Unlock()
+	if colorState.override != nil {
+		return *colorState.override
+	}
+	if colorState.stdout == nil {
+		level := DetectColorLevel(os.Stdout)
+		colorState.stdout = &level
+	}
+	return *colorState.stdout
+}
+
+// colorKind is the kind of a Color.
+type colorKind uint8
+
+const (
+	colorDefault colorKind = iota
+	colorBasic
+	colorPalette
+	colorRGB
+)
+
+// Color is a foreground or background color of a Style.
+// Zero value is the default color of the terminal.
+type Color struct {
+	kind    colorKind
+	r, g, b uint8 // Basic foreground code and palette index are stored in r.
+}
+
+// BasicColor returns one of 16 ANSI colors by foreground code,
+// i.e. 30-37 or 90-97 like the Gray, Red, Green, Yellow, Blue and Magenta constants.
+func BasicColor(code uint8) Color {
+	return Color{kind: colorBasic, r: code}
+}
+
+// PaletteColor returns a color of the 256-color palette.
+func PaletteColor(index uint8) Color {
+	return Color{kind: colorPalette, r: index}
+}
+
+// RGBColor returns a 24-bit true color.
+func RGBColor(r, g, b uint8) Color {
+	return Color{kind: colorRGB, r: r, g: g, b: b}
+}
+
+// HexColor returns a true color by hex notation like "#ff8800" or "f80".
+func HexColor(hex string) (Color, error) {
+	s := strings.TrimPrefix(hex, "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) != 6 {
+		return Color{}, fmt.Errorf("invalid hex color: %s", hex)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return Color{}, fmt.Errorf("invalid hex color: %s", hex)
+	}
+	return RGBColor(uint8(v>>16), uint8(v>>8), uint8(v)), nil
+}
+
+// basicRGB holds approximate values of 16 ANSI colors in xterm.
+var basicRGB = [16][3]uint8{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// rgb returns approximate RGB value of a non-default color.
+func (c Color) rgb() (uint8, uint8, uint8) {
+	switch c.kind {
+	case colorBasic:
+		i := int(c.r) - 30
+		if c.r >= 90 {
+			i = int(c.r) - 90 + 8
+		}
+		if i < 0 || i > 15 {
+			i = 7
+		}
+		return basicRGB[i][0], basicRGB[i][1], basicRGB[i][2]
+	case colorPalette:
+		switch n := int(c.r); {
+		case n < 16:
+			return basicRGB[n][0], basicRGB[n][1], basicRGB[n][2]
+		case n < 232:
+			n -= 16
+			level := func(v int) uint8 {
+				if v == 0 {
+					return 0
+				}
+				return uint8(55 + v*40)
+			}
+			return level(n / 36), level(n / 6 % 6), level(n % 6)
+		default:
+			v := uint8(8 + (n-232)*10)
+			return v, v, v
+		}
+	}
+	return c.r, c.g, c.b
+}
+
+// toPalette converts a true color to the closest one of 256-color palette.
+func (c Color) toPalette() Color {
+	r, g, b := c.rgb()
+	if r == g && g == b {
+		switch {
+		case r < 8:
+			return PaletteColor(16)
+		case r > 248:
+			return PaletteColor(231)
+		}
+		return PaletteColor(uint8(232 + (int(r)-8)/10))
+	}
+	cube := func(v uint8) int {
+		if v < 48 {
+			return 0
+		}
+		if v < 115 {
+			return 1
+		}
+		return (int(v) - 35) / 40
+	}
+	return PaletteColor(uint8(16 + 36*cube(r) + 6*cube(g) + cube(b)))
+}
+
+// toBasic converts a color to the closest one of 16 ANSI colors.
+func (c Color) toBasic() Color {
+	r, g, b := c.rgb()
+	best, dist := 0, -1
+	for i, v := range basicRGB {
+		dr, dg, db := int(r)-int(v[0]), int(g)-int(v[1]), int(b)-int(v[2])
+		if d := dr*dr + dg*dg + db*db; dist < 0 || d < dist {
+			best, dist = i, d
+		}
+	}
+	if best < 8 {
+		return BasicColor(uint8(30 + best))
+	}
+	return BasicColor(uint8(90 + best - 8))
+}
+
+// code returns SGR parameters of the color supported by given level,
+// background colors are offset from foreground ones.
+func (c Color) code(level ColorLevel, background bool) string {
+	if c.kind == colorDefault || level == ColorNone {
+		return ""
+	}
+	if c.kind == colorRGB && level < ColorTrueColor {
+		c = c.toPalette()
+	}
+	if c.kind == colorPalette && level < Color256 {
+		c = c.toBasic()
+	}
+
+	prefix := "38"
+	if background {
+		prefix = "48"
+	}
+	switch c.kind {
+	case colorBasic:
+		code := int(c.r)
+		if background {
+			code += 10
+		}
+		return strconv.Itoa(code)
+	case colorPalette:
+		return prefix + ";5;" + strconv.Itoa(int(c.r))
+	}
+	return fmt.Sprintf("%s;2;%d;%d;%d", prefix, c.r, c.g, c.b)
+}
+
+// Text attributes by their SGR codes.
+const (
+	attrBold      = 1
+	attrDim       = 2
+	attrItalic    = 3
+	attrUnderline = 4
+	attrBlink     = 5
+	attrReverse   = 7
+	attrStrike    = 9
+)
+
+// Style describes colors and attributes of text. Methods return
+// modified copies, so styles can be built by chaining and shared:
+//
+//	warn := com.NewStyle().Foreground(com.BasicColor(com.Yellow)).Bold()
+//	fmt.Println(warn.Sprint("careful"))
+type Style struct {
+	fg, bg Color
+	attrs  []int
+}
+
+// NewStyle returns a style without any color or attribute.
+func NewStyle() Style {
+	return Style{}
+}
+
+// Foreground returns a copy of the style with given text color.
+func (s Style) Foreground(c Color) Style {
+	s.fg = c
+	return s
+}
+
+// Background returns a copy of the style with given background color.
+func (s Style) Background(c Color) Style {
+	s.bg = c
+	return s
+}
+
+func (s Style) withAttr(attr int) Style {
+	for _, a := range s.attrs {
+		if a == attr {
+			return s
+		}
+	}
+	s.attrs = append(append(make([]int, 0, len(s.attrs)+1), s.attrs...), attr)
+	return s
+}
+
+// Bold returns a copy of the style with bold text.
+func (s Style) Bold() Style { return s.withAttr(attrBold) }
+
+// Dim returns a copy of the style with faint text.
+func (s Style) Dim() Style { return s.withAttr(attrDim) }
+
+// Italic returns a copy of the style with italic text.
+func (s Style) Italic() Style { return s.withAttr(attrItalic) }
+
+// Underline returns a copy of the style with underlined text.
+func (s Style) Underline() Style { return s.withAttr(attrUnderline) }
+
+// Blink returns a copy of the style with blinking text.
+func (s Style) Blink() Style { return s.withAttr(attrBlink) }
+
+// Reverse returns a copy of the style with swapped foreground and background.
+func (s Style) Reverse() Style { return s.withAttr(attrReverse) }
+
+// Strikethrough returns a copy of the style with crossed-out text.
+func (s Style) Strikethrough() Style { return s.withAttr(attrStrike) }
+
+// Sequence returns the escape sequence that turns the style on for given
+// color level, colors are converted to the closest supported ones.
+// It returns empty string for ColorNone or a style without effects.
+func (s Style) Sequence(level ColorLevel) string {
+	if level == ColorNone {
+		return ""
+	}
+	params := make([]string, 0, len(s.attrs)+2)
+	for _, a := range s.attrs {
+		params = append(params, strconv.Itoa(a))
+	}
+	if code := s.fg.code(level, false); len(code) > 0 {
+		params = append(params, code)
+	}
+	if code := s.bg.code(level, true); len(code) > 0 {
+		params = append(params, code)
+	}
+	if len(params) == 0 {
+		return ""
+	}
+	return "\033[" + strings.Join(params, ";") + "m"
+}
+
+// Render returns text wrapped in escape sequences of the style for given color level.
+func (s Style) Render(level ColorLevel, text string) string {
+	seq := s.Sequence(level)
+	if len(seq) == 0 {
+		return text
+	}
+	return seq + text + EndColor
+}
+
+// Sprint formats operands like fmt.Sprint and applies the style
+// according to StdoutColorLevel.
+func (s Style) Sprint(a ...interface{}) string {
+	return s.Render(StdoutColorLevel(), fmt.Sprint(a...))
+}
+
+// Sprintf formats like fmt.Sprintf and applies the style
+// according to StdoutColorLevel.
+func (s Style) Sprintf(format string, a ...interface{}) string {
+	return s.Render(StdoutColorLevel(), fmt.Sprintf(format, a...))
+}
+
+// ansiLen returns length of the escape sequence at the start of s,
+// or 0 if s does not start with one. CSI and OSC sequences are recognized.
+func ansiLen(s string) int {
+	if len(s) < 2 || s[0] != '\033' {
+		return 0
+	}
+	switch s[1] {
+	case '[':
+		// Parameters and intermediates, ended by a byte in range 0x40-0x7E.
+		for i := 2; i < len(s); i++ {
+			if s[i] >= 0x40 && s[i] <= 0x7E {
+				return i + 1
+			}
+		}
+		return len(s)
+	case ']':
+		// Ended by BEL or ST (ESC \).
+		for i := 2; i < len(s); i++ {
+			if s[i] == '\a' {
+				return i + 1
+			}
+			if s[i] == '\033' && i+1 < len(s) && s[i+1] == '\\' {
+				return i + 2
+			}
+		}
+		return len(s)
+	}
+	return 2
+}
+
+// StripANSI removes ANSI escape sequences from s.
+func StripANSI(s string) string {
+	if !strings.Contains(s, "\033") {
+		return s
+	}
+	buf := make([]byte, 0, len(s))
+	for i := 0; i < len(s); {
+		if n := ansiLen(s[i:]); n > 0 {
+			i += n
+			continue
+		}
+		buf = append(buf, s[i])
+		i++
+	}
+	return string(buf)
+}
+
+// wideRanges holds East Asian wide and fullwidth characters and emoji.
+var wideRanges = [][2]rune{
+	{0x1100, 0x115F}, {0x231A, 0x231B}, {0x2329, 0x232A}, {0x23E9, 0x23EC},
+	{0x23F0, 0x23F0}, {0x23F3, 0x23F3}, {0x25FD, 0x25FE}, {0x2614, 0x2615},
+	{0x2648, 0x2653}, {0x267F, 0x267F}, {0x2693, 0x2693}, {0x26A1, 0x26A1},
+	{0x26AA, 0x26AB}, {0x26BD, 0x26BE}, {0x26C4, 0x26C5}, {0x26CE, 0x26CE},
+	{0x26D4, 0x26D4}, {0x26EA, 0x26EA}, {0x26F2, 0x26F3}, {0x26F5, 0x26F5},
+	{0x26FA, 0x26FA}, {0x26FD, 0x26FD}, {0x2705, 0x2705}, {0x270A, 0x270B},
+	{0x2728, 0x2728}, {0x274C, 0x274C}, {0x274E, 0x274E}, {0x2753, 0x2755},
+	{0x2757, 0x2757}, {0x2795, 0x2797}, {0x27B0, 0x27B0}, {0x27BF, 0x27BF},
+	{0x2B1B, 0x2B1C}, {0x2B50, 0x2B50}, {0x2B55, 0x2B55}, {0x2E80, 0x303E},
+	{0x3041, 0x33FF}, {0x3400, 0x4DBF}, {0x4E00, 0x9FFF}, {0xA000, 0xA4CF},
+	{0xA960, 0xA97F}, {0xAC00, 0xD7A3}, {0xF900, 0xFAFF}, {0xFE10, 0xFE19},
+	{0xFE30, 0xFE6F}, {0xFF00, 0xFF60}, {0xFFE0, 0xFFE6}, {0x16FE0, 0x16FE4},
+	{0x17000, 0x18AFF}, {0x1B000, 0x1B2FF}, {0x1F004, 0x1F004}, {0x1F0CF, 0x1F0CF},
+	{0x1F18E, 0x1F18E}, {0x1F191, 0x1F19A}, {0x1F200, 0x1F251}, {0x1F300, 0x1F64F},
+	{0x1F680, 0x1F6FF}, {0x1F7E0, 0x1F7EB}, {0x1F90C, 0x1F9FF}, {0x1FA70, 0x1FAFF},
+	{0x20000, 0x2FFFD}, {0x30000, 0x3FFFD},
+}
+
+// RuneWidth returns number of terminal columns taken by r:
+// 0 for control and combining characters, 2 for East Asian wide
+// characters and emoji, 1 for others.
+func RuneWidth(r rune) int {
+	switch {
+	case r < 0x20 || r >= 0x7F && r < 0xA0:
+		return 0
+	case r < 0x300:
+		return 1
+	case r == 0x200B || r == 0x200C || r == 0x200D || r == 0xFEFF ||
+		unicode.In(r, unicode.Mn, unicode.Me) || r >= 0xFE00 && r <= 0xFE0F:
+		return 0
+	}
+
+	// Binary search in sorted ranges.
+	lo, hi := 0, len(wideRanges)-1
+	for lo <= hi {
+		mid := (lo + hi) / 2
+		switch {
+		case r < wideRanges[mid][0]:
+			hi = mid - 1
+		case r > wideRanges[mid][1]:
+			lo = mid + 1
+		default:
+			return 2
+		}
+	}
+	return 1
+}
+
+// StringWidth returns number of terminal columns taken by s,
+// ANSI escape sequences are not counted.
+func StringWidth(s string) int {
+	width := 0
+	for i := 0; i < len(s); {
+		if n := ansiLen(s[i:]); n > 0 {
+			i += n
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		width += RuneWidth(r)
+		i += size
+	}
+	return width
+}
+
+// PadRight appends spaces to s until it takes width columns,
+// ANSI escape sequences are not counted.
+func PadRight(s string, width int) string {
+	if n := width - StringWidth(s); n > 0 {
+		return s + strings.Repeat(" ", n)
+	}
+	return s
+}
+
+// PadLeft prepends spaces to s until it takes width columns,
+// ANSI escape sequences are not counted.
+func PadLeft(s string, width int) string {
+	if n := width - StringWidth(s); n > 0 {
+		return strings.Repeat(" ", n) + s
+	}
+	return s
+}
+
+// TruncateWidth cuts s to take at most width columns including tail,
+// which is appended when s is cut, e.g. "...". Escape sequences are kept,
+// and styles are reset after a cut styled string.
+func TruncateWidth(s string, width int, tail string) string {
+	if StringWidth(s) <= width {
+		return s
+	}
+	limit := width - StringWidth(tail)
+	if limit < 0 {
+		limit = 0
+	}
+
+	buf := make([]byte, 0, len(s))
+	styled := false
+	cols := 0
+	for i := 0; i < len(s); {
+		if n := ansiLen(s[i:]); n > 0 {
+			buf = append(buf, s[i:i+n]...)
+			styled = true
+			i += n
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		w := RuneWidth(r)
+		if cols+w > limit {
+			break
+		}
+		cols += w
+		buf = append(buf, s[i:i+size]...)
+		i += size
+	}
+	if styled {
+		buf = append(buf, EndColor...)
+	}
+	return string(buf) + tail
+}
@@ -0,0 +1,166 @@
+// Copyright 2013 com authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package com
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDetectColorLevel(t *testing.T) {
+	cases := []struct {
+		env    string
+		tty    bool
+		expect ColorLevel
+	}{
+		{"", false, ColorNone},
+		{"", true, Color16},
+		{"TERM=xterm-256color", true, Color256},
+		{"TERM=xterm-256color COLORTERM=truecolor", true, ColorTrueColor},
+		{"TERM=dumb", true, ColorNone},
+		{"NO_COLOR=1", true, ColorNone},
+		{"NO_COLOR=", true, Color16},
+		{"FORCE_COLOR=", false, Color16},
+		{"FORCE_COLOR=1 NO_COLOR=1", false, Color16},
+		{"FORCE_COLOR=2", false, Color256},
+		{"FORCE_COLOR=3 TERM=dumb", false, ColorTrueColor},
+		{"FORCE_COLOR=1 COLORTERM=24bit", false, ColorTrueColor},
+		{"FORCE_COLOR=0", true, ColorNone},
+		{"FORCE_COLOR=false", true, ColorNone},
+	}
+	for _, c := range cases {
+		env := make(map[string]string)
+		for _, kv := range strings.Fields(c.env) {
+			kv := strings.SplitN(kv, "=", 2)
+			env[kv[0]] = kv[1]
+		}
+		lookupEnv := func(key string) (string, bool) {
+			v, ok := env[key]
+			return v, ok
+		}
+		level := detectColorLevel(lookupEnv, func() bool { return c.tty })
+		if level != c.expect {
+			t.Errorf("detectColorLevel(%q, %v):\n Expect => %s\n Got => %s\n", c.env, c.tty, c.expect, level)
+		}
+	}
+}
+
+func TestSetColorLevel(t *testing.T) {
+	defer ResetColorLevel()
+
+	SetColorLevel(ColorTrueColor)
+	if level := StdoutColorLevel(); level != ColorTrueColor {
+		t.Errorf("StdoutColorLevel:\n Expect => %s\n Got => %s\n", ColorTrueColor, level)
+	}
+	SetColorLevel(ColorNone)
+	if cls := ColorLogS("[ERRO] plain[ text ]"); cls != "[ERRO] plain[text]" {
+		t.Errorf("ColorLogS:\n Expect => %s\n Got => %s\n", "[ERRO] plain[text]", cls)
+	}
+
+	ResetColorLevel()
+	if expect := DetectColorLevel(os.Stdout); StdoutColorLevel() != expect {
+		t.Errorf("StdoutColorLevel:\n Expect => %s\n Got => %s\n", expect, StdoutColorLevel())
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+	f, err := os.Open("color.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if IsTerminal(f) || IsTerminal(nil) {
+		t.Errorf("IsTerminal:\n Expect => %v\n Got => %v\n", false, true)
+	}
+}
+
+func TestStyle(t *testing.T) {
+	s := NewStyle().Foreground(BasicColor(Red)).Bold().Bold()
+	if seq := s.Sequence(Color16); seq != "\033[1;91m" {
+		t.Errorf("Style.Sequence:\n Expect => %q\n Got => %q\n", "\033[1;91m", seq)
+	}
+	if text := s.Render(ColorNone, "text"); text != "text" {
+		t.Errorf("Style.Render:\n Expect => %q\n Got => %q\n", "text", text)
+	}
+
+	orange, err := HexColor("#ff8800")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = NewStyle().Foreground(orange).Background(PaletteColor(236)).Underline().Italic()
+	cases := map[ColorLevel]string{
+		ColorTrueColor: "\033[4;3;38;2;255;136;0;48;5;236mtext\033[0m",
+		Color256:       "\033[4;3;38;5;208;48;5;236mtext\033[0m",
+		Color16:        "\033[4;3;33;40mtext\033[0m",
+	}
+	for level, expect := range cases {
+		if text := s.Render(level, "text"); text != expect {
+			t.Errorf("Style.Render(%s):\n Expect => %q\n Got => %q\n", level, expect, text)
+		}
+	}
+
+	if _, err = HexColor("#ff88"); err == nil {
+		t.Errorf("HexColor:\n Expect => %s\n Got => %v\n", "error", err)
+	}
+	if c, _ := HexColor("f80"); c != orange {
+		t.Errorf("HexColor:\n Expect => %v\n Got => %v\n", orange, c)
+	}
+}
+
+func TestStringWidth(t *testing.T) {
+	styled := NewStyle().Foreground(BasicColor(Green)).Render(Color16, "ok")
+	cases := map[string]int{
+		"":                          0,
+		"hello":                     5,
+		styled:                      2,
+		"\033]0;title\a中文":          4,
+		"ｈｅｌｌｏ":                     10,
+		"e\u0301":                   1,
+		"👍":                         2,
+		"\033[38;2;1;2;3mab\033[0m": 2,
+	}
+	for s, expect := range cases {
+		if w := StringWidth(s); w != expect {
+			t.Errorf("StringWidth(%q):\n Expect => %d\n Got => %d\n", s, expect, w)
+		}
+	}
+
+	if s := StripANSI(styled); s != "ok" {
+		t.Errorf("StripANSI:\n Expect => %q\n Got => %q\n", "ok", s)
+	}
+	if s := PadRight(styled, 4); s != styled+"  " {
+		t.Errorf("PadRight:\n Expect => %q\n Got => %q\n", styled+"  ", s)
+	}
+	if s := PadLeft("中", 3); s != " 中" {
+		t.Errorf("PadLeft:\n Expect => %q\n Got => %q\n", " 中", s)
+	}
+
+	truncates := []struct {
+		s, tail string
+		width   int
+		expect  string
+	}{
+		{"hello world", "...", 8, "hello..."},
+		{"hello", "...", 5, "hello"},
+		{"中文字符", "…", 5, "中文…"},
+		{styled + "!!", "", 1, "\033[92mo\033[0m"},
+	}
+	for _, c := range truncates {
+		if s := TruncateWidth(c.s, c.width, c.tail); s != c.expect {
+			t.Errorf("TruncateWidth(%q, %d):\n Expect => %q\n Got => %q\n", c.s, c.width, c.expect, s)
+		}
+	}
+}
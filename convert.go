@@ -15,35 +15,149 @@
 package com
 
 import (
+	"encoding"
+	"errors"
 	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // Convert string to specify type.
 type StrTo string
 
+// StrToError is returned by StrTo conversions when the string
+// cannot be converted to the requested type.
+type StrToError struct {
+	Input string
+	Type  string
+	Err   error // e.g. *strconv.NumError, strconv.ErrSyntax or strconv.ErrRange.
+}
+
+func (e *StrToError) Error() string {
+	err := e.Err
+	if ne, ok := err.(*strconv.NumError); ok {
+		err = ne.Err
+	}
+	return fmt.Sprintf("cannot convert %q to %s: %v", e.Input, e.Type, err)
+}
+
+// Unwrap returns the underlying error.
+func (e *StrToError) Unwrap() error {
+	return e.Err
+}
+
+// newError returns *StrToError for err, or nil if err is nil.
+func (f StrTo) newError(typ string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &StrToError{Input: f.String(), Type: typ, Err: err}
+}
+
+func (f StrTo) parseInt(typ string, base, bitSize int) (int64, error) {
+	v, err := strconv.ParseInt(f.String(), base, bitSize)
+	return v, f.newError(typ, err)
+}
+
+func (f StrTo) parseUint(typ string, base, bitSize int) (uint64, error) {
+	v, err := strconv.ParseUint(f.String(), base, bitSize)
+	return v, f.newError(typ, err)
+}
+
 func (f StrTo) Exist() bool {
 	return string(f) != string(rune(0x1E))
 }
 
 func (f StrTo) Uint8() (uint8, error) {
-	v, err := strconv.ParseUint(f.String(), 10, 8)
+	v, err := f.parseUint("uint8", 10, 8)
 	return uint8(v), err
 }
 
+func (f StrTo) Uint16() (uint16, error) {
+	v, err := f.parseUint("uint16", 10, 16)
+	return uint16(v), err
+}
+
+func (f StrTo) Uint32() (uint32, error) {
+	v, err := f.parseUint("uint32", 10, 32)
+	return uint32(v), err
+}
+
+func (f StrTo) Uint64() (uint64, error) {
+	return f.parseUint("uint64", 10, 64)
+}
+
+func (f StrTo) Uint() (uint, error) {
+	v, err := f.parseUint("uint", 10, strconv.IntSize)
+	return uint(v), err
+}
+
+func (f StrTo) Int8() (int8, error) {
+	v, err := f.parseInt("int8", 10, 8)
+	return int8(v), err
+}
+
+func (f StrTo) Int16() (int16, error) {
+	v, err := f.parseInt("int16", 10, 16)
+	return int16(v), err
+}
+
+func (f StrTo) Int32() (int32, error) {
+	v, err := f.parseInt("int32", 10, 32)
+	return int32(v), err
+}
+
 func (f StrTo) Int() (int, error) {
-	v, err := strconv.ParseInt(f.String(), 10, 0)
+	v, err := f.parseInt("int", 10, 0)
 	return int(v), err
 }
 
 func (f StrTo) Int64() (int64, error) {
-	v, err := strconv.ParseInt(f.String(), 10, 64)
-	return int64(v), err
+	return f.parseInt("int64", 10, 64)
+}
+
+func (f StrTo) Float32() (float32, error) {
+	v, err := strconv.ParseFloat(f.String(), 32)
+	return float32(v), f.newError("float32", err)
 }
 
 func (f StrTo) Float64() (float64, error) {
 	v, err := strconv.ParseFloat(f.String(), 64)
-	return float64(v), err
+	return v, f.newError("float64", err)
+}
+
+// Bool accepts 1, t, T, TRUE, true, True, 0, f, F, FALSE, false, False.
+func (f StrTo) Bool() (bool, error) {
+	v, err := strconv.ParseBool(f.String())
+	return v, f.newError("bool", err)
+}
+
+// Duration accepts formats of time.ParseDuration, e.g. "1h30m".
+func (f StrTo) Duration() (time.Duration, error) {
+	v, err := time.ParseDuration(f.String())
+	return v, f.newError("time.Duration", err)
+}
+
+// Time parses the string with given layout of time.Parse.
+func (f StrTo) Time(layout string) (time.Time, error) {
+	v, err := time.Parse(layout, f.String())
+	return v, f.newError("time.Time", err)
+}
+
+// BigInt parses the string as a decimal integer of any size.
+func (f StrTo) BigInt() (*big.Int, error) {
+	v, ok := new(big.Int).SetString(f.String(), 10)
+	if !ok {
+		return nil, f.newError("big.Int", strconv.ErrSyntax)
+	}
+	return v, nil
 }
 
 func (f StrTo) MustUint8() uint8 {
@@ -51,6 +165,41 @@ func (f StrTo) MustUint8() uint8 {
 	return v
 }
 
+func (f StrTo) MustUint16() uint16 {
+	v, _ := f.Uint16()
+	return v
+}
+
+func (f StrTo) MustUint32() uint32 {
+	v, _ := f.Uint32()
+	return v
+}
+
+func (f StrTo) MustUint64() uint64 {
+	v, _ := f.Uint64()
+	return v
+}
+
+func (f StrTo) MustUint() uint {
+	v, _ := f.Uint()
+	return v
+}
+
+func (f StrTo) MustInt8() int8 {
+	v, _ := f.Int8()
+	return v
+}
+
+func (f StrTo) MustInt16() int16 {
+	v, _ := f.Int16()
+	return v
+}
+
+func (f StrTo) MustInt32() int32 {
+	v, _ := f.Int32()
+	return v
+}
+
 func (f StrTo) MustInt() int {
 	v, _ := f.Int()
 	return v
@@ -61,11 +210,250 @@ func (f StrTo) MustInt64() int64 {
 	return v
 }
 
+func (f StrTo) MustFloat32() float32 {
+	v, _ := f.Float32()
+	return v
+}
+
 func (f StrTo) MustFloat64() float64 {
 	v, _ := f.Float64()
 	return v
 }
 
+func (f StrTo) MustBool() bool {
+	v, _ := f.Bool()
+	return v
+}
+
+func (f StrTo) MustDuration() time.Duration {
+	v, _ := f.Duration()
+	return v
+}
+
+func (f StrTo) MustTime(layout string) time.Time {
+	v, _ := f.Time(layout)
+	return v
+}
+
+// MustBigInt returns zero when the string is not a valid integer.
+func (f StrTo) MustBigInt() *big.Int {
+	v, err := f.BigInt()
+	if err != nil {
+		return new(big.Int)
+	}
+	return v
+}
+
+// MustXxxOr methods return def instead of zero value when conversion fails.
+
+func (f StrTo) MustUint8Or(def uint8) uint8 {
+	if v, err := f.Uint8(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (f StrTo) MustUint16Or(def uint16) uint16 {
+	if v, err := f.Uint16(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (f StrTo) MustUint32Or(def uint32) uint32 {
+	if v, err := f.Uint32(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (f StrTo) MustUint64Or(def uint64) uint64 {
+	if v, err := f.Uint64(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (f StrTo) MustUintOr(def uint) uint {
+	if v, err := f.Uint(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (f StrTo) MustInt8Or(def int8) int8 {
+	if v, err := f.Int8(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (f StrTo) MustInt16Or(def int16) int16 {
+	if v, err := f.Int16(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (f StrTo) MustInt32Or(def int32) int32 {
+	if v, err := f.Int32(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (f StrTo) MustIntOr(def int) int {
+	if v, err := f.Int(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (f StrTo) MustInt64Or(def int64) int64 {
+	if v, err := f.Int64(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (f StrTo) MustFloat32Or(def float32) float32 {
+	if v, err := f.Float32(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (f StrTo) MustFloat64Or(def float64) float64 {
+	if v, err := f.Float64(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (f StrTo) MustBoolOr(def bool) bool {
+	if v, err := f.Bool(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (f StrTo) MustDurationOr(def time.Duration) time.Duration {
+	if v, err := f.Duration(); err == nil {
+		return v
+	}
+	return def
+}
+
+func (f StrTo) MustTimeOr(layout string, def time.Time) time.Time {
+	if v, err := f.Time(layout); err == nil {
+		return v
+	}
+	return def
+}
+
+func (f StrTo) MustBigIntOr(def *big.Int) *big.Int {
+	if v, err := f.BigInt(); err == nil {
+		return v
+	}
+	return def
+}
+
+var (
+	// ErrOutOfRange is wrapped by StrToError when the value is outside of given range.
+	ErrOutOfRange = errors.New("not in range")
+	// ErrNotAllowed is wrapped by StrToError when the value is not in the allowed set.
+	ErrNotAllowed = errors.New("not one of")
+)
+
+// IntRange converts the string to int and checks it is within [min, max].
+func (f StrTo) IntRange(min, max int) (int, error) {
+	v, err := f.Int()
+	if err != nil {
+		return v, err
+	}
+	if v < min || v > max {
+		return v, f.newError("int", fmt.Errorf("%w [%d, %d]", ErrOutOfRange, min, max))
+	}
+	return v, nil
+}
+
+// Int64Range converts the string to int64 and checks it is within [min, max].
+func (f StrTo) Int64Range(min, max int64) (int64, error) {
+	v, err := f.Int64()
+	if err != nil {
+		return v, err
+	}
+	if v < min || v > max {
+		return v, f.newError("int64", fmt.Errorf("%w [%d, %d]", ErrOutOfRange, min, max))
+	}
+	return v, nil
+}
+
+// Float64Range converts the string to float64 and checks it is within [min, max].
+func (f StrTo) Float64Range(min, max float64) (float64, error) {
+	v, err := f.Float64()
+	if err != nil {
+		return v, err
+	}
+	if math.IsNaN(v) || v < min || v > max {
+		return v, f.newError("float64", fmt.Errorf("%w [%v, %v]", ErrOutOfRange, min, max))
+	}
+	return v, nil
+}
+
+// DurationRange converts the string to time.Duration and checks it is within [min, max].
+func (f StrTo) DurationRange(min, max time.Duration) (time.Duration, error) {
+	v, err := f.Duration()
+	if err != nil {
+		return v, err
+	}
+	if v < min || v > max {
+		return v, f.newError("time.Duration", fmt.Errorf("%w [%v, %v]", ErrOutOfRange, min, max))
+	}
+	return v, nil
+}
+
+// IntIn converts the string to int and checks it is one of allowed values.
+func (f StrTo) IntIn(allowed ...int) (int, error) {
+	v, err := f.Int()
+	if err != nil {
+		return v, err
+	}
+	for _, a := range allowed {
+		if v == a {
+			return v, nil
+		}
+	}
+	return v, f.newError("int", fmt.Errorf("%w %v", ErrNotAllowed, allowed))
+}
+
+// StringIn checks the string is one of allowed values.
+func (f StrTo) StringIn(allowed ...string) (string, error) {
+	v := f.String()
+	for _, a := range allowed {
+		if v == a {
+			return v, nil
+		}
+	}
+	return v, f.newError("string", fmt.Errorf("%w %q", ErrNotAllowed, allowed))
+}
+
+// MustIntRangeOr returns def when the string is not a valid int within [min, max].
+func (f StrTo) MustIntRangeOr(min, max, def int) int {
+	if v, err := f.IntRange(min, max); err == nil {
+		return v
+	}
+	return def
+}
+
+// MustStringInOr returns def when the string is not one of allowed values.
+func (f StrTo) MustStringInOr(def string, allowed ...string) string {
+	if v, err := f.StringIn(allowed...); err == nil {
+		return v
+	}
+	return def
+}
+
 func (f StrTo) String() string {
 	if f.Exist() {
 		return string(f)
@@ -74,8 +462,40 @@ func (f StrTo) String() string {
 }
 
 // Convert any type to string.
-func ToStr(value interface{}, args ...int) (s string) {
+//
+// The optional args[0] is the base of integers or precision of floats,
+// args[1] is the bit size of floats. Other types are converted
+// by ToStrWith with default options.
+func ToStr(value interface{}, args ...int) string {
+	return ToStrWith(value, ToStrOptions{}, args...)
+}
+
+// ToStrOptions controls how ToStrWith converts composite and time values.
+type ToStrOptions struct {
+	// TimeLayout is the layout of time.Time, default is time.RFC3339Nano.
+	TimeLayout string
+	// Sep separates items of slices, arrays and maps, default is ",".
+	Sep string
+	// KVSep separates keys and values of maps, default is "=".
+	KVSep string
+}
+
+var (
+	errorType         = reflect.TypeOf((*error)(nil)).Elem()
+	stringerType      = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// ToStrWith converts any type to string, args are the same as ToStr.
+// Pointers are dereferenced and nil becomes empty string; time.Time is
+// formatted with opts.TimeLayout; error, fmt.Stringer and
+// encoding.TextMarshaler are honored; named types are converted by their underlying kind;
+// slices, arrays and maps are joined with opts.Sep, map entries are
+// sorted by key.
+func ToStrWith(value interface{}, opts ToStrOptions, args ...int) (s string) {
 	switch v := value.(type) {
+	case nil:
+		return ""
 	case bool:
 		s = strconv.FormatBool(v)
 	case float32:
@@ -106,12 +526,159 @@ func ToStr(value interface{}, args ...int) (s string) {
 		s = v
 	case []byte:
 		s = string(v)
+	case time.Time:
+		layout := opts.TimeLayout
+		if layout == "" {
+			layout = time.RFC3339Nano
+		}
+		s = v.Format(layout)
 	default:
-		s = fmt.Sprintf("%v", v)
+		s = toStrValue(reflect.ValueOf(value), opts, args)
 	}
 	return s
 }
 
+func toStrValue(v reflect.Value, opts ToStrOptions, args []int) string {
+	// Dereference pointers unless the method only exists on pointer receiver, e.g. *big.Int.
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return ""
+		}
+		if !hasStrMethod(v.Type()) || hasStrMethod(v.Type().Elem()) {
+			return ToStrWith(v.Elem().Interface(), opts, args...)
+		}
+	}
+
+	if v.Type().Implements(errorType) {
+		return v.Interface().(error).Error()
+	}
+	if v.Type().Implements(stringerType) {
+		return v.Interface().(fmt.Stringer).String()
+	}
+	if v.Type().Implements(textMarshalerType) {
+		if text, err := v.Interface().(encoding.TextMarshaler).MarshalText(); err == nil {
+			return string(text)
+		}
+	}
+
+	sep := opts.Sep
+	if sep == "" {
+		sep = ","
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), argInt(args).Get(0, 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(v.Uint(), argInt(args).Get(0, 10))
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', argInt(args).Get(0, -1), argInt(args).Get(1, v.Type().Bits()))
+	case reflect.String:
+		return v.String()
+	case reflect.Slice, reflect.Array:
+		if v.Type().Elem().Kind() == reflect.Uint8 && v.Kind() == reflect.Slice {
+			return string(v.Bytes())
+		}
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = ToStrWith(v.Index(i).Interface(), opts, args...)
+		}
+		return strings.Join(items, sep)
+	case reflect.Map:
+		kvSep := opts.KVSep
+		if kvSep == "" {
+			kvSep = "="
+		}
+		keys := make([]string, 0, v.Len())
+		vals := make(map[string]string, v.Len())
+		for _, key := range v.MapKeys() {
+			k := ToStrWith(key.Interface(), opts, args...)
+			keys = append(keys, k)
+			vals[k] = ToStrWith(v.MapIndex(key).Interface(), opts, args...)
+		}
+		sort.Strings(keys)
+		for i, k := range keys {
+			keys[i] = k + kvSep + vals[k]
+		}
+		return strings.Join(keys, sep)
+	}
+	return fmt.Sprintf("%v", v.Interface())
+}
+
+func hasStrMethod(t reflect.Type) bool {
+	return t.Implements(errorType) || t.Implements(stringerType) || t.Implements(textMarshalerType)
+}
+
+// FromStr parses s into the value pointed by target, it is the reverse of ToStr.
+// Supported targets are pointers to bool, integers, floats, string, []byte
+// and time.Duration, including named types of them, and any encoding.TextUnmarshaler.
+// The optional args[0] is the base of integers, default is 10.
+func FromStr(s string, target interface{}, args ...int) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("FromStr: expect non-nil pointer, got %T", target)
+	}
+	if u, ok := target.(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	v = v.Elem()
+
+	f := StrTo(s)
+	if v.Type() == durationType {
+		d, err := f.Duration()
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		nv := reflect.New(v.Type().Elem())
+		if err := FromStr(s, nv.Interface(), args...); err != nil {
+			return err
+		}
+		v.Set(nv)
+	case reflect.Bool:
+		b, err := f.Bool()
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := f.parseInt(v.Kind().String(), argInt(args).Get(0, 10), v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := f.parseUint(v.Kind().String(), argInt(args).Get(0, 10), v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return f.newError(v.Kind().String(), err)
+		}
+		v.SetFloat(n)
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("FromStr: unsupported type %s", v.Type())
+		}
+		v.SetBytes([]byte(s))
+	default:
+		return fmt.Errorf("FromStr: unsupported type %s", v.Type())
+	}
+	return nil
+}
+
 type argInt []int
 
 func (a argInt) Get(i int, args ...int) (r int) {
@@ -123,45 +690,228 @@ func (a argInt) Get(i int, args ...int) (r int) {
 	return
 }
 
-// HexStr2int converts hex format string to decimal number.
+// HexStr2int converts hex format string to decimal number,
+// it is case-insensitive and accepts optional sign and "0x" prefix.
 func HexStr2int(hexStr string) (int, error) {
-	num := 0
-	length := len(hexStr)
-	for i := 0; i < length; i++ {
-		char := hexStr[length-i-1]
-		factor := -1
-
-		switch {
-		case char >= '0' && char <= '9':
-			factor = int(char) - '0'
-		case char >= 'a' && char <= 'f':
-			factor = int(char) - 'a' + 10
-		default:
-			return -1, fmt.Errorf("invalid hex: %s", string(char))
-		}
+	sign := ""
+	if len(hexStr) > 0 && (hexStr[0] == '-' || hexStr[0] == '+') {
+		sign, hexStr = hexStr[:1], hexStr[1:]
+	}
+	if len(hexStr) > 2 && hexStr[0] == '0' && (hexStr[1] == 'x' || hexStr[1] == 'X') {
+		hexStr = hexStr[2:]
+	}
 
-		num += factor * PowInt(16, i)
+	v, err := Base16.DecodeInt64(sign + hexStr)
+	if err == nil && int64(int(v)) != v {
+		err = StrTo(sign+hexStr).newError("int", strconv.ErrRange)
 	}
-	return num, nil
+	if err != nil {
+		return -1, err
+	}
+	return int(v), nil
 }
 
 // Int2HexStr converts decimal number to hex format string.
 func Int2HexStr(num int) (hex string) {
-	if num == 0 {
-		return "0"
+	return Base16.EncodeInt64(int64(num))
+}
+
+// BaseDigits is the alphabet of base 2 to 62, NewBaseCodec(BaseDigits[:n])
+// returns the codec of base n, which is compatible with strconv for n <= 36.
+const BaseDigits = "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+var (
+	Base16 = mustBaseCodec(BaseDigits[:16])
+	Base36 = mustBaseCodec(BaseDigits[:36])
+	Base62 = mustBaseCodec(BaseDigits)
+	// Base58Bitcoin is the alphabet used by Bitcoin addresses, without 0, O, I and l.
+	Base58Bitcoin = mustBaseCodec("123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz")
+	// Base32Crockford is Douglas Crockford's base 32, which decodes I, L as 1 and O as 0.
+	Base32Crockford = mustBaseCodec("0123456789ABCDEFGHJKMNPQRSTVWXYZ").alias("Ii", '1').alias("Ll", '1').alias("Oo", '0')
+)
+
+// BaseCodec encodes and decodes integers in the base of its alphabet.
+// Decoding is case-insensitive when all letters of the alphabet
+// are in the same case.
+type BaseCodec struct {
+	alphabet string
+	index    [256]int16 // Value of each byte, -1 for invalid.
+}
+
+// NewBaseCodec returns a codec of given alphabet, its length is the base.
+// Alphabet must have at least 2 unique ASCII characters other than sign.
+func NewBaseCodec(alphabet string) (*BaseCodec, error) {
+	if len(alphabet) < 2 {
+		return nil, fmt.Errorf("base alphabet %q is too short", alphabet)
+	}
+
+	c := &BaseCodec{alphabet: alphabet}
+	for i := range c.index {
+		c.index[i] = -1
+	}
+	for i := 0; i < len(alphabet); i++ {
+		ch := alphabet[i]
+		if ch >= utf8.RuneSelf || ch == '-' || ch == '+' {
+			return nil, fmt.Errorf("base alphabet %q has invalid character %q", alphabet, ch)
+		}
+		if c.index[ch] >= 0 {
+			return nil, fmt.Errorf("base alphabet %q has duplicated character %q", alphabet, ch)
+		}
+		c.index[ch] = int16(i)
+	}
+
+	hasLower := strings.IndexFunc(alphabet, unicode.IsLower) >= 0
+	hasUpper := strings.IndexFunc(alphabet, unicode.IsUpper) >= 0
+	if hasLower != hasUpper {
+		for i := 0; i < len(alphabet); i++ {
+			switch ch := alphabet[i]; {
+			case ch >= 'a' && ch <= 'z':
+				c.index[ch-'a'+'A'] = int16(i)
+			case ch >= 'A' && ch <= 'Z':
+				c.index[ch-'A'+'a'] = int16(i)
+			}
+		}
+	}
+	return c, nil
+}
+
+func mustBaseCodec(alphabet string) *BaseCodec {
+	c, err := NewBaseCodec(alphabet)
+	if err != nil {
+		panic(err)
+	}
+	return c
+}
+
+// alias decodes chars as the same value of target.
+func (c *BaseCodec) alias(chars string, target byte) *BaseCodec {
+	for i := 0; i < len(chars); i++ {
+		c.index[chars[i]] = c.index[target]
+	}
+	return c
+}
+
+// Base returns the base of codec.
+func (c *BaseCodec) Base() int {
+	return len(c.alphabet)
+}
+
+func (c *BaseCodec) EncodeUint64(n uint64) string {
+	if n == 0 {
+		return c.alphabet[:1]
+	}
+
+	var buf [64]byte
+	i := len(buf)
+	base := uint64(len(c.alphabet))
+	for n > 0 {
+		i--
+		buf[i] = c.alphabet[n%base]
+		n /= base
 	}
+	return string(buf[i:])
+}
+
+// EncodeInt64 encodes n with "-" prefix when it is negative.
+func (c *BaseCodec) EncodeInt64(n int64) string {
+	if n < 0 {
+		// Negation of math.MinInt64 overflows but its uint64 conversion is still right.
+		return "-" + c.EncodeUint64(uint64(-n))
+	}
+	return c.EncodeUint64(uint64(n))
+}
 
-	for num > 0 {
-		r := num % 16
+// EncodeBigInt encodes n with "-" prefix when it is negative.
+func (c *BaseCodec) EncodeBigInt(n *big.Int) string {
+	if n.IsUint64() {
+		return c.EncodeUint64(n.Uint64())
+	}
+
+	var buf []byte
+	q := new(big.Int).Abs(n)
+	base := big.NewInt(int64(len(c.alphabet)))
+	r := new(big.Int)
+	for q.Sign() > 0 {
+		q.QuoRem(q, base, r)
+		buf = append(buf, c.alphabet[r.Int64()])
+	}
+	if n.Sign() < 0 {
+		buf = append(buf, '-')
+	}
+	for i, j := 0, len(buf)-1; i < j; i, j = i+1, j-1 {
+		buf[i], buf[j] = buf[j], buf[i]
+	}
+	return string(buf)
+}
+
+// DecodeUint64 decodes s, it returns *StrToError wrapping strconv.ErrSyntax
+// for invalid input or strconv.ErrRange when the value overflows.
+func (c *BaseCodec) DecodeUint64(s string) (uint64, error) {
+	if s == "" {
+		return 0, StrTo(s).newError("uint64", strconv.ErrSyntax)
+	}
 
-		c := "?"
-		if r >= 0 && r <= 9 {
-			c = string(rune(r + '0'))
-		} else {
-			c = string(rune(r + 'a' - 10))
+	base := uint64(len(c.alphabet))
+	cutoff := math.MaxUint64 / base
+	var n uint64
+	for i := 0; i < len(s); i++ {
+		d := c.index[s[i]]
+		if d < 0 {
+			return 0, StrTo(s).newError("uint64", strconv.ErrSyntax)
 		}
-		hex = c + hex
-		num = num / 16
+		if n > cutoff || n*base > math.MaxUint64-uint64(d) {
+			return math.MaxUint64, StrTo(s).newError("uint64", strconv.ErrRange)
+		}
+		n = n*base + uint64(d)
+	}
+	return n, nil
+}
+
+// DecodeInt64 decodes s with optional sign, see DecodeUint64 for errors.
+func (c *BaseCodec) DecodeInt64(s string) (int64, error) {
+	neg, digits := splitSign(s)
+	u, err := c.DecodeUint64(digits)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return 0, StrTo(s).newError("int64", strconv.ErrSyntax)
+	}
+
+	switch {
+	case neg && (err != nil || u > 1<<63):
+		return math.MinInt64, StrTo(s).newError("int64", strconv.ErrRange)
+	case neg:
+		return -int64(u), nil
+	case err != nil || u > math.MaxInt64:
+		return math.MaxInt64, StrTo(s).newError("int64", strconv.ErrRange)
+	}
+	return int64(u), nil
+}
+
+// DecodeBigInt decodes s with optional sign into integer of any size.
+func (c *BaseCodec) DecodeBigInt(s string) (*big.Int, error) {
+	neg, digits := splitSign(s)
+	if digits == "" {
+		return nil, StrTo(s).newError("big.Int", strconv.ErrSyntax)
+	}
+
+	n := new(big.Int)
+	base := big.NewInt(int64(len(c.alphabet)))
+	d := new(big.Int)
+	for i := 0; i < len(digits); i++ {
+		v := c.index[digits[i]]
+		if v < 0 {
+			return nil, StrTo(s).newError("big.Int", strconv.ErrSyntax)
+		}
+		n.Mul(n, base).Add(n, d.SetInt64(int64(v)))
+	}
+	if neg {
+		n.Neg(n)
+	}
+	return n, nil
+}
+
+func splitSign(s string) (neg bool, digits string) {
+	if len(s) > 0 && (s[0] == '-' || s[0] == '+') {
+		return s[0] == '-', s[1:]
 	}
-	return hex
+	return false, s
 }
@@ -15,7 +15,14 @@
 package com
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strconv"
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -36,6 +43,32 @@ func TestHexStr2int(t *testing.T) {
 			So(val, ShouldEqual, dec)
 		}
 	})
+
+	Convey("Convert uppercase, prefixed and negative hex", t, func() {
+		hexDecs := map[string]int{
+			"FF":   255,
+			"0x1A": 26,
+			"0XfF": 255,
+			"-ff":  -255,
+		}
+
+		for hex, dec := range hexDecs {
+			val, err := HexStr2int(hex)
+			So(err, ShouldBeNil)
+			So(val, ShouldEqual, dec)
+		}
+	})
+
+	Convey("Reject invalid and overflowed hex", t, func() {
+		for _, hex := range []string{"", "0x", "xyz", "1 2"} {
+			val, err := HexStr2int(hex)
+			So(errors.Is(err, strconv.ErrSyntax), ShouldBeTrue)
+			So(val, ShouldEqual, -1)
+		}
+
+		_, err := HexStr2int("10000000000000000000")
+		So(errors.Is(err, strconv.ErrRange), ShouldBeTrue)
+	})
 }
 
 func TestInt2HexStr(t *testing.T) {
@@ -53,4 +86,351 @@ func TestInt2HexStr(t *testing.T) {
 			So(val, ShouldEqual, hex)
 		}
 	})
+
+	Convey("Convert zero and negative decimal to hex format string", t, func() {
+		So(Int2HexStr(0), ShouldEqual, "0")
+		So(Int2HexStr(-255), ShouldEqual, "-ff")
+	})
+}
+
+func TestBaseCodec(t *testing.T) {
+	Convey("Encode and decode integers", t, func() {
+		So(Base62.EncodeUint64(61), ShouldEqual, "Z")
+		So(Base62.EncodeUint64(62), ShouldEqual, "10")
+		So(Base36.EncodeInt64(-35), ShouldEqual, "-z")
+		So(Base58Bitcoin.EncodeUint64(0), ShouldEqual, "1")
+		So(Base32Crockford.EncodeUint64(1234567), ShouldEqual, "15NM7")
+
+		for _, c := range []*BaseCodec{Base16, Base32Crockford, Base36, Base58Bitcoin, Base62} {
+			for _, n := range []int64{0, 1, -1, 1 << 40, math.MaxInt64, math.MinInt64} {
+				v, err := c.DecodeInt64(c.EncodeInt64(n))
+				So(err, ShouldBeNil)
+				So(v, ShouldEqual, n)
+			}
+
+			v, err := c.DecodeUint64(c.EncodeUint64(math.MaxUint64))
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, uint64(math.MaxUint64))
+		}
+	})
+
+	Convey("Match strconv for base up to 36", t, func() {
+		for base := 2; base <= 36; base++ {
+			c, err := NewBaseCodec(BaseDigits[:base])
+			So(err, ShouldBeNil)
+			So(c.Base(), ShouldEqual, base)
+			So(c.EncodeInt64(-123456789), ShouldEqual, strconv.FormatInt(-123456789, base))
+		}
+	})
+
+	Convey("Encode and decode big integers", t, func() {
+		n, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+		s := Base62.EncodeBigInt(n)
+		v, err := Base62.DecodeBigInt(s)
+		So(err, ShouldBeNil)
+		So(v.Cmp(n), ShouldEqual, 0)
+
+		So(Base36.EncodeBigInt(n), ShouldEqual, n.Text(36))
+		So(Base16.EncodeBigInt(big.NewInt(255)), ShouldEqual, "ff")
+
+		_, err = Base62.DecodeBigInt("-")
+		So(errors.Is(err, strconv.ErrSyntax), ShouldBeTrue)
+	})
+
+	Convey("Decode case-insensitively when unambiguous", t, func() {
+		v, _ := Base36.DecodeUint64("ZZ")
+		So(v, ShouldEqual, 36*36-1)
+
+		v, _ = Base32Crockford.DecodeUint64("15nm7")
+		So(v, ShouldEqual, 1234567)
+		v, _ = Base32Crockford.DecodeUint64("iLo")
+		So(v, ShouldEqual, 1*32*32+1*32+0)
+
+		a, _ := Base62.DecodeUint64("a")
+		A, _ := Base62.DecodeUint64("A")
+		So(a, ShouldNotEqual, A)
+
+		_, err := Base58Bitcoin.DecodeUint64("l")
+		So(errors.Is(err, strconv.ErrSyntax), ShouldBeTrue)
+		_, err = Base58Bitcoin.DecodeUint64("0")
+		So(errors.Is(err, strconv.ErrSyntax), ShouldBeTrue)
+	})
+
+	Convey("Detect overflow", t, func() {
+		_, err := Base62.DecodeUint64("zzzzzzzzzzzz")
+		So(errors.Is(err, strconv.ErrRange), ShouldBeTrue)
+		So(err.Error(), ShouldEqual, `cannot convert "zzzzzzzzzzzz" to uint64: value out of range`)
+
+		_, err = Base16.DecodeInt64("8000000000000000")
+		So(errors.Is(err, strconv.ErrRange), ShouldBeTrue)
+		_, err = Base16.DecodeInt64("-8000000000000001")
+		So(errors.Is(err, strconv.ErrRange), ShouldBeTrue)
+		_, err = Base16.DecodeInt64("-fffffffffffffffffff")
+		So(errors.Is(err, strconv.ErrRange), ShouldBeTrue)
+	})
+
+	Convey("Reject invalid alphabet", t, func() {
+		for _, alphabet := range []string{"", "0", "aa", "01-", "01é"} {
+			_, err := NewBaseCodec(alphabet)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
+
+func TestStrTo(t *testing.T) {
+	Convey("Convert string to numeric types", t, func() {
+		u16, err := StrTo("65535").Uint16()
+		So(err, ShouldBeNil)
+		So(u16, ShouldEqual, 65535)
+
+		u64, err := StrTo("18446744073709551615").Uint64()
+		So(err, ShouldBeNil)
+		So(u64, ShouldEqual, uint64(18446744073709551615))
+
+		i8, err := StrTo("-128").Int8()
+		So(err, ShouldBeNil)
+		So(i8, ShouldEqual, -128)
+
+		i32, err := StrTo("-2147483648").Int32()
+		So(err, ShouldBeNil)
+		So(i32, ShouldEqual, -2147483648)
+
+		f32, err := StrTo("1.5").Float32()
+		So(err, ShouldBeNil)
+		So(f32, ShouldEqual, 1.5)
+
+		n, err := StrTo("123456789012345678901234567890").BigInt()
+		So(err, ShouldBeNil)
+		So(n.String(), ShouldEqual, "123456789012345678901234567890")
+	})
+
+	Convey("Convert string to bool, duration and time", t, func() {
+		b, err := StrTo("TRUE").Bool()
+		So(err, ShouldBeNil)
+		So(b, ShouldBeTrue)
+
+		d, err := StrTo("1h30m").Duration()
+		So(err, ShouldBeNil)
+		So(d, ShouldEqual, 90*time.Minute)
+
+		tm, err := StrTo("2014-05-06").Time("2006-01-02")
+		So(err, ShouldBeNil)
+		So(tm.Equal(time.Date(2014, 5, 6, 0, 0, 0, 0, time.UTC)), ShouldBeTrue)
+	})
+
+	Convey("Report conversion errors", t, func() {
+		_, err := StrTo("256").Uint8()
+		So(err, ShouldNotBeNil)
+		So(errors.Is(err, strconv.ErrRange), ShouldBeTrue)
+		So(err.Error(), ShouldEqual, `cannot convert "256" to uint8: value out of range`)
+
+		_, err = StrTo("abc").Int()
+		So(errors.Is(err, strconv.ErrSyntax), ShouldBeTrue)
+
+		var numErr *strconv.NumError
+		_, err = StrTo("1e400").Float64()
+		So(errors.As(err, &numErr), ShouldBeTrue)
+		So(numErr.Func, ShouldEqual, "ParseFloat")
+		So(numErr.Err, ShouldEqual, strconv.ErrRange)
+
+		_, err = StrTo("-1").Uint()
+		So(err, ShouldNotBeNil)
+
+		_, err = StrTo("1.5x").BigInt()
+		var e *StrToError
+		So(errors.As(err, &e), ShouldBeTrue)
+		So(e.Type, ShouldEqual, "big.Int")
+		So(e.Input, ShouldEqual, "1.5x")
+
+		_, err = StrTo("soon").Duration()
+		So(errors.As(err, &e), ShouldBeTrue)
+		So(e.Type, ShouldEqual, "time.Duration")
+	})
+
+	Convey("Use zero or default value on failure", t, func() {
+		So(StrTo("x").MustInt(), ShouldEqual, 0)
+		So(StrTo("x").MustIntOr(42), ShouldEqual, 42)
+		So(StrTo("7").MustIntOr(42), ShouldEqual, 7)
+		So(StrTo("70000").MustUint16Or(1), ShouldEqual, 1)
+		So(StrTo("yes").MustBoolOr(true), ShouldBeTrue)
+		So(StrTo("x").MustDurationOr(time.Second), ShouldEqual, time.Second)
+		So(StrTo("x").MustBigInt().Sign(), ShouldEqual, 0)
+		So(StrTo("x").MustBigIntOr(big.NewInt(5)).Int64(), ShouldEqual, 5)
+
+		def := time.Unix(0, 0)
+		So(StrTo("x").MustTimeOr("2006", def).Equal(def), ShouldBeTrue)
+	})
+}
+
+func TestStrToBounds(t *testing.T) {
+	Convey("Check converted value is within range", t, func() {
+		v, err := StrTo("50").IntRange(1, 100)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, 50)
+
+		_, err = StrTo("150").IntRange(1, 100)
+		So(errors.Is(err, ErrOutOfRange), ShouldBeTrue)
+		So(err.Error(), ShouldEqual, `cannot convert "150" to int: not in range [1, 100]`)
+
+		_, err = StrTo("x").IntRange(1, 100)
+		So(errors.Is(err, strconv.ErrSyntax), ShouldBeTrue)
+
+		_, err = StrTo("-5").Int64Range(0, 10)
+		So(errors.Is(err, ErrOutOfRange), ShouldBeTrue)
+
+		_, err = StrTo("0.5").Float64Range(0, 1)
+		So(err, ShouldBeNil)
+		_, err = StrTo("NaN").Float64Range(0, 1)
+		So(errors.Is(err, ErrOutOfRange), ShouldBeTrue)
+
+		_, err = StrTo("2h").DurationRange(time.Second, time.Hour)
+		So(err.Error(), ShouldEqual, `cannot convert "2h" to time.Duration: not in range [1s, 1h0m0s]`)
+
+		So(StrTo("0").MustIntRangeOr(1, 10, 5), ShouldEqual, 5)
+		So(StrTo("3").MustIntRangeOr(1, 10, 5), ShouldEqual, 3)
+	})
+
+	Convey("Check converted value is in allowed set", t, func() {
+		v, err := StrTo("8").IntIn(2, 4, 8)
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, 8)
+
+		_, err = StrTo("3").IntIn(2, 4, 8)
+		So(errors.Is(err, ErrNotAllowed), ShouldBeTrue)
+		So(err.Error(), ShouldEqual, `cannot convert "3" to int: not one of [2 4 8]`)
+
+		_, err = StrTo("Debug").StringIn("debug", "info")
+		So(err.Error(), ShouldEqual, `cannot convert "Debug" to string: not one of ["debug" "info"]`)
+
+		So(StrTo("trace").MustStringInOr("info", "debug", "info"), ShouldEqual, "info")
+	})
+}
+
+func TestFromStr(t *testing.T) {
+	Convey("Parse string into typed targets", t, func() {
+		var b bool
+		So(FromStr("true", &b), ShouldBeNil)
+		So(b, ShouldBeTrue)
+
+		var i8 int8
+		So(FromStr("-7f", &i8, 16), ShouldBeNil)
+		So(i8, ShouldEqual, -127)
+
+		var u uint
+		So(FromStr("101", &u, 2), ShouldBeNil)
+		So(u, ShouldEqual, 5)
+
+		var f32 float32
+		So(FromStr("2.5", &f32), ShouldBeNil)
+		So(f32, ShouldEqual, 2.5)
+
+		var bs []byte
+		So(FromStr("abc", &bs), ShouldBeNil)
+		So(string(bs), ShouldEqual, "abc")
+
+		type level int
+		var lv level
+		So(FromStr("3", &lv), ShouldBeNil)
+		So(lv, ShouldEqual, 3)
+
+		var p *int
+		So(FromStr("9", &p), ShouldBeNil)
+		So(*p, ShouldEqual, 9)
+
+		var n big.Int
+		So(FromStr("123456789012345678901234567890", &n), ShouldBeNil)
+		So(n.String(), ShouldEqual, "123456789012345678901234567890")
+	})
+
+	Convey("Round trip with ToStr", t, func() {
+		for _, v := range []interface{}{true, int16(-300), uint64(1 << 63), 3.25, "str"} {
+			ptr := reflect.New(reflect.TypeOf(v))
+			So(FromStr(ToStr(v), ptr.Interface()), ShouldBeNil)
+			So(ptr.Elem().Interface(), ShouldEqual, v)
+		}
+
+		var hex uint32
+		So(FromStr(ToStr(uint32(0xbeef), 16), &hex, 16), ShouldBeNil)
+		So(hex, ShouldEqual, 0xbeef)
+
+		var d time.Duration
+		So(FromStr(ToStr(90*time.Second), &d), ShouldBeNil)
+		So(d, ShouldEqual, 90*time.Second)
+	})
+
+	Convey("Report invalid input and targets", t, func() {
+		var i8 int8
+		err := FromStr("200", &i8)
+		So(errors.Is(err, strconv.ErrRange), ShouldBeTrue)
+		So(err.Error(), ShouldEqual, `cannot convert "200" to int8: value out of range`)
+
+		var f float64
+		So(FromStr("x", &f), ShouldNotBeNil)
+
+		So(FromStr("1", i8), ShouldNotBeNil)
+		So(FromStr("1", (*int)(nil)), ShouldNotBeNil)
+		So(FromStr("2014-05-06T00:00:00Z", (*time.Time)(nil)), ShouldNotBeNil)
+
+		var m map[string]int
+		So(FromStr("1", &m), ShouldNotBeNil)
+	})
+}
+
+type toStrLevel int
+
+func (l toStrLevel) String() string {
+	return [...]string{"debug", "info"}[l]
+}
+
+type toStrIP [4]byte
+
+func (ip *toStrIP) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d.%d.%d.%d", ip[0], ip[1], ip[2], ip[3])), nil
+}
+
+func TestToStr(t *testing.T) {
+	Convey("Convert primitive types to string", t, func() {
+		So(ToStr(true), ShouldEqual, "true")
+		So(ToStr(255, 16), ShouldEqual, "ff")
+		So(ToStr(uint8(5), 2), ShouldEqual, "101")
+		So(ToStr(1.2345, 2), ShouldEqual, "1.23")
+		So(ToStr(float32(0.1)), ShouldEqual, "0.1")
+		So(ToStr([]byte("abc")), ShouldEqual, "abc")
+		So(ToStr(nil), ShouldEqual, "")
+	})
+
+	Convey("Convert pointers, named and special types to string", t, func() {
+		n := 42
+		pn := &n
+		So(ToStr(&n), ShouldEqual, "42")
+		So(ToStr(&pn), ShouldEqual, "42")
+		So(ToStr((*int)(nil)), ShouldEqual, "")
+
+		type port uint16
+		type ratio float32
+		So(ToStr(port(8080)), ShouldEqual, "8080")
+		So(ToStr(ratio(0.5)), ShouldEqual, "0.5")
+		So(ToStr(toStrLevel(1)), ShouldEqual, "info")
+
+		So(ToStr(90*time.Second), ShouldEqual, "1m30s")
+		tm := time.Date(2014, 5, 6, 7, 8, 9, 0, time.UTC)
+		So(ToStr(tm), ShouldEqual, "2014-05-06T07:08:09Z")
+		So(ToStr(&tm), ShouldEqual, "2014-05-06T07:08:09Z")
+		So(ToStrWith(tm, ToStrOptions{TimeLayout: "2006-01-02"}), ShouldEqual, "2014-05-06")
+
+		So(ToStr(big.NewInt(1<<62)), ShouldEqual, "4611686018427387904")
+		So(ToStr(&toStrIP{127, 0, 0, 1}), ShouldEqual, "127.0.0.1")
+		So(ToStr(errors.New("oops")), ShouldEqual, "oops")
+	})
+
+	Convey("Join slices and maps", t, func() {
+		So(ToStr([]int{10, 11}, 16), ShouldEqual, "a,b")
+		So(ToStr([2]string{"a", "b"}), ShouldEqual, "a,b")
+		So(ToStr([]interface{}{1, nil, "x"}), ShouldEqual, "1,,x")
+		So(ToStrWith([]toStrLevel{0, 1}, ToStrOptions{Sep: " | "}), ShouldEqual, "debug | info")
+
+		m := map[string]int{"b": 2, "a1": 11, "a": 1}
+		So(ToStr(m), ShouldEqual, "a=1,a1=11,b=2")
+		So(ToStrWith(m, ToStrOptions{Sep: "&", KVSep: ":"}), ShouldEqual, "a:1&a1:11&b:2")
+	})
 }
@@ -15,11 +15,19 @@
 package com
 
 import (
+	"bytes"
+	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
 )
 
 // IsDir returns true if given path is a directory,
@@ -32,113 +40,380 @@ func IsDir(dir string) bool {
 	return f.IsDir()
 }
 
-func statDir(dirPath, recPath string, includeDir, isDirOnly, followSymlinks bool) ([]string, error) {
-	dir, err := os.Open(dirPath)
-	if err != nil {
-		return nil, err
+// SkipDir is used as a return value from WalkFunc to indicate that
+// the directory named in the call is to be skipped.
+var SkipDir = filepath.SkipDir
+
+// SymlinkPolicy controls how symbolic links are handled.
+type SymlinkPolicy int
+
+const (
+	// SymlinkAsFile lists symbolic links as files without following them.
+	SymlinkAsFile SymlinkPolicy = iota
+	// SymlinkFollow follows symbolic links, relative targets are resolved
+	// against directory of the link. Links to directories are descended
+	// unless they point to the directory or one of its ancestors, which
+	// is detected by device and inode. Broken links are listed as files.
+	SymlinkFollow
+	// SymlinkSkip omits symbolic links.
+	SymlinkSkip
+)
+
+// WalkEntry is a file or directory found by Walk.
+type WalkEntry struct {
+	// Path is relative to the root with slash separator, e.g. "a/b.txt".
+	Path string
+	// FullPath is the root joined with Path.
+	FullPath string
+	// Depth is 1 for direct children of the root.
+	Depth int
+	// Info is of the link target when symbolic links are followed.
+	Info os.FileInfo
+	// Symlink is true if the entry is a symbolic link.
+	Symlink bool
+
+	parent *WalkEntry
+}
+
+// IsDir returns true if the entry is a directory.
+func (e *WalkEntry) IsDir() bool {
+	return e.Info.IsDir()
+}
+
+// inCycle returns true if e is the same directory as one of its ancestors.
+func (e *WalkEntry) inCycle() bool {
+	for p := e.parent; p != nil; p = p.parent {
+		if os.SameFile(p.Info, e.Info) {
+			return true
+		}
 	}
-	defer dir.Close()
+	return false
+}
 
-	fis, err := dir.Readdir(0)
+// WalkOptions controls how Walk traverses directories.
+type WalkOptions struct {
+	// MaxDepth limits depth of visited entries, 0 means no limit.
+	MaxDepth int
+	// Workers is the number of directories read concurrently,
+	// default is runtime.NumCPU().
+	Workers int
+	// Symlinks is the policy of symbolic links, default is SymlinkAsFile.
+	Symlinks SymlinkPolicy
+	// Include reports whether to visit the entry, directories
+	// are still descended when they are not included.
+	Include func(e *WalkEntry) bool
+	// Exclude reports whether to neither visit nor descend the entry.
+	Exclude func(e *WalkEntry) bool
+}
+
+// WalkFunc is called by Walk for every visited entry. When reading a
+// directory fails, it is called again with the entry of directory and the
+// error, the root has empty Path. Returning SkipDir of a directory skips
+// its content, and other non-nil error stops the walk.
+type WalkFunc func(e *WalkEntry, err error) error
+
+// walkBatchSize is the maximum number of entries read at a time.
+const walkBatchSize = 256
+
+type walkBatch struct {
+	dir   *WalkEntry
+	infos []os.FileInfo
+	err   error
+	done  bool
+}
+
+// Walk walks the file tree of root, root itself is not visited.
+// Directories are read concurrently but fn is called serially, so entries
+// are visited in no particular order except that a directory is visited
+// before its content.
+func Walk(root string, opts WalkOptions, fn WalkFunc) error {
+	return WalkContext(context.Background(), root, opts, fn)
+}
+
+// WalkContext is like Walk but stops when the context is done.
+func WalkContext(ctx context.Context, root string, opts WalkOptions, fn WalkFunc) error {
+	fi, err := os.Stat(root)
 	if err != nil {
-		return nil, err
+		return err
+	} else if !fi.IsDir() {
+		return errors.New("not a directory: " + root)
 	}
 
-	statList := make([]string, 0)
-	for _, fi := range fis {
-		if strings.Contains(fi.Name(), ".DS_Store") {
-			continue
-		}
+	workers := opts.Workers
+	if workers < 1 {
+		workers = runtime.NumCPU()
+	}
 
-		relPath := path.Join(recPath, fi.Name())
-		curPath := path.Join(dirPath, fi.Name())
-		if fi.IsDir() {
-			if includeDir {
-				statList = append(statList, relPath+"/")
+	jobs := make(chan *WalkEntry)
+	batches := make(chan walkBatch)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for dir := range jobs {
+				readDirBatches(dir, batches, done)
 			}
-			s, err := statDir(curPath, relPath, includeDir, isDirOnly, followSymlinks)
-			if err != nil {
-				return nil, err
+		}()
+	}
+	defer func() {
+		close(done)
+		close(jobs)
+		wg.Wait()
+	}()
+
+	queue := []*WalkEntry{{FullPath: root, Info: fi}}
+	pending := 1 // Directories queued or being read.
+	for pending > 0 {
+		var next chan *WalkEntry
+		var dir *WalkEntry
+		if len(queue) > 0 {
+			next, dir = jobs, queue[0]
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case next <- dir:
+			queue = queue[1:]
+		case b := <-batches:
+			if b.done {
+				pending--
 			}
-			statList = append(statList, s...)
-		} else if !isDirOnly {
-			statList = append(statList, relPath)
-		} else if followSymlinks && fi.Mode()&os.ModeSymlink != 0 {
-			link, err := os.Readlink(curPath)
-			if err != nil {
-				return nil, err
+			if b.err != nil {
+				if err = fn(b.dir, b.err); err != nil && err != SkipDir {
+					return err
+				}
 			}
-
-			if IsDir(link) {
-				if includeDir {
-					statList = append(statList, relPath+"/")
+			for _, info := range b.infos {
+				e := walkEntry(b.dir, info, &opts)
+				if e == nil {
+					continue
 				}
-				s, err := statDir(curPath, relPath, includeDir, isDirOnly, followSymlinks)
-				if err != nil {
-					return nil, err
+				err = walkVisit(e, &opts, fn)
+				if err == nil && e.IsDir() && (opts.MaxDepth <= 0 || e.Depth < opts.MaxDepth) &&
+					!(e.Symlink && e.inCycle()) {
+					queue = append(queue, e)
+					pending++
+				} else if err != nil && err != SkipDir {
+					return err
 				}
-				statList = append(statList, s...)
 			}
 		}
 	}
+	return nil
+}
+
+// readDirBatches reads dir in batches, the last one is marked as done.
+func readDirBatches(dir *WalkEntry, batches chan<- walkBatch, done <-chan struct{}) {
+	send := func(b walkBatch) bool {
+		select {
+		case batches <- b:
+			return true
+		case <-done:
+			return false
+		}
+	}
+
+	f, err := os.Open(dir.FullPath)
+	if err != nil {
+		send(walkBatch{dir: dir, err: err, done: true})
+		return
+	}
+	defer f.Close()
+
+	for {
+		infos, err := f.Readdir(walkBatchSize)
+		if err == io.EOF {
+			send(walkBatch{dir: dir, infos: infos, done: true})
+			return
+		} else if err != nil {
+			send(walkBatch{dir: dir, infos: infos, err: err, done: true})
+			return
+		}
+		if !send(walkBatch{dir: dir, infos: infos}) {
+			return
+		}
+	}
+}
+
+// walkEntry returns entry of info in dir, or nil if it is excluded.
+func walkEntry(dir *WalkEntry, info os.FileInfo, opts *WalkOptions) *WalkEntry {
+	e := &WalkEntry{
+		Path:     path.Join(dir.Path, info.Name()),
+		FullPath: filepath.Join(dir.FullPath, info.Name()),
+		Depth:    dir.Depth + 1,
+		Info:     info,
+		parent:   dir,
+	}
+	if opts.MaxDepth > 0 && e.Depth > opts.MaxDepth {
+		return nil
+	}
+
+	if info.Mode()&os.ModeSymlink != 0 {
+		e.Symlink = true
+		switch opts.Symlinks {
+		case SymlinkSkip:
+			return nil
+		case SymlinkFollow:
+			if fi, err := os.Stat(e.FullPath); err == nil {
+				e.Info = fi
+			}
+		}
+	}
+
+	if opts.Exclude != nil && opts.Exclude(e) {
+		return nil
+	}
+	return e
+}
+
+// walkVisit calls fn with e if it is included.
+func walkVisit(e *WalkEntry, opts *WalkOptions, fn WalkFunc) error {
+	if opts.Include != nil && !opts.Include(e) {
+		return nil
+	}
+	return fn(e, nil)
+}
+
+// WalkResult is an entry or error streamed by WalkChan.
+type WalkResult struct {
+	Entry *WalkEntry
+	Err   error
+}
+
+// WalkChan walks the file tree of root like Walk and streams results
+// through the returned channel, which is closed when the walk is finished.
+// Errors of reading directories are sent with the entry of directory.
+// Cancel the context to stop the walk early.
+func WalkChan(ctx context.Context, root string, opts WalkOptions) <-chan WalkResult {
+	results := make(chan WalkResult)
+	go func() {
+		defer close(results)
+		err := WalkContext(ctx, root, opts, func(e *WalkEntry, err error) error {
+			select {
+			case results <- WalkResult{Entry: e, Err: err}:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			results <- WalkResult{Err: err}
+		}
+	}()
+	return results
+}
+
+// isDSStore excludes .DS_Store files of macOS.
+func isDSStore(e *WalkEntry) bool {
+	return strings.Contains(e.Info.Name(), ".DS_Store")
+}
+
+// StatDirOptions controls how StatDirWith gathers information of directory.
+type StatDirOptions struct {
+	// IncludeDir includes subdirectories with suffix '/'.
+	IncludeDir bool
+	// DirOnly includes subdirectories only.
+	DirOnly bool
+	// Symlinks is the policy of symbolic links, default is SymlinkAsFile.
+	Symlinks SymlinkPolicy
+	// Exclude skips entries and content of directories it returns true.
+	Exclude func(e *WalkEntry) bool
+}
+
+// StatDirWith gathers information of given directory by Walk with options.
+// It returns sorted slice of file list; it returns error and nil slice when
+// error occurs in underlying functions, or given path is not a directory
+// or does not exist.
+func StatDirWith(rootPath string, opts StatDirOptions) ([]string, error) {
+	if !IsDir(rootPath) {
+		return nil, errors.New("not a directory or does not exist: " + rootPath)
+	}
+
+	statList := make([]string, 0)
+	walkOpts := WalkOptions{
+		Symlinks: opts.Symlinks,
+		Exclude:  isDSStore,
+	}
+	if opts.Exclude != nil {
+		walkOpts.Exclude = func(e *WalkEntry) bool {
+			return isDSStore(e) || opts.Exclude(e)
+		}
+	}
+	err := Walk(rootPath, walkOpts, func(e *WalkEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if e.IsDir() {
+			if opts.IncludeDir || opts.DirOnly {
+				statList = append(statList, e.Path+"/")
+			}
+		} else if !opts.DirOnly {
+			statList = append(statList, e.Path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(statList)
 	return statList, nil
 }
 
-// StatDir gathers information of given directory by depth-first.
-// It returns slice of file list and includes subdirectories if enabled;
+// StatDir gathers information of given directory by Walk.
+// It returns sorted slice of file list and includes subdirectories if enabled;
 // it returns error and nil slice when error occurs in underlying functions,
 // or given path is not a directory or does not exist.
 //
 // Slice does not include given path itself.
 // If subdirectories is enabled, they will have suffix '/'.
 func StatDir(rootPath string, includeDir ...bool) ([]string, error) {
-	if !IsDir(rootPath) {
-		return nil, errors.New("not a directory or does not exist: " + rootPath)
-	}
-
 	isIncludeDir := false
 	if len(includeDir) >= 1 {
 		isIncludeDir = includeDir[0]
 	}
-	return statDir(rootPath, "", isIncludeDir, false, false)
+	return StatDirWith(rootPath, StatDirOptions{IncludeDir: isIncludeDir})
 }
 
-// LstatDir gathers information of given directory by depth-first.
-// It returns slice of file list, follows symbolic links and includes subdirectories if enabled;
+// LstatDir gathers information of given directory by Walk.
+// It returns sorted slice of file list, follows symbolic links and includes subdirectories if enabled;
 // it returns error and nil slice when error occurs in underlying functions,
 // or given path is not a directory or does not exist.
 //
 // Slice does not include given path itself.
 // If subdirectories is enabled, they will have suffix '/'.
 func LstatDir(rootPath string, includeDir ...bool) ([]string, error) {
-	if !IsDir(rootPath) {
-		return nil, errors.New("not a directory or does not exist: " + rootPath)
+	isIncludeDir := false
+	if len(includeDir) >= 1 {
+		isIncludeDir = includeDir[0]
 	}
+	return StatDirWith(rootPath, StatDirOptions{IncludeDir: isIncludeDir, Symlinks: SymlinkFollow})
+}
 
+// StatDirExclude is like StatDir but skips entries and content of directories
+// that exclude returns true, e.g. StatDirExclude(root, ignore.Exclude) with
+// ignore from LoadGitIgnore(root).
+func StatDirExclude(rootPath string, exclude func(e *WalkEntry) bool, includeDir ...bool) ([]string, error) {
 	isIncludeDir := false
 	if len(includeDir) >= 1 {
 		isIncludeDir = includeDir[0]
 	}
-	return statDir(rootPath, "", isIncludeDir, false, true)
+	return StatDirWith(rootPath, StatDirOptions{IncludeDir: isIncludeDir, Exclude: exclude})
 }
 
 // GetAllSubDirs returns all subdirectories of given root path.
 // Slice does not include given path itself.
 func GetAllSubDirs(rootPath string) ([]string, error) {
-	if !IsDir(rootPath) {
-		return nil, errors.New("not a directory or does not exist: " + rootPath)
-	}
-	return statDir(rootPath, "", true, true, false)
+	return StatDirWith(rootPath, StatDirOptions{DirOnly: true})
 }
 
 // LgetAllSubDirs returns all subdirectories of given root path, including
 // following symbolic links, if any.
 // Slice does not include given path itself.
 func LgetAllSubDirs(rootPath string) ([]string, error) {
-	if !IsDir(rootPath) {
-		return nil, errors.New("not a directory or does not exist: " + rootPath)
-	}
-	return statDir(rootPath, "", true, true, true)
+	return StatDirWith(rootPath, StatDirOptions{DirOnly: true, Symlinks: SymlinkFollow})
 }
 
 // GetFileListBySuffix returns an ordered list of file paths.
@@ -171,48 +446,264 @@ func GetFileListBySuffix(dirPath, suffix string) ([]string, error) {
 	return files, nil
 }
 
+// OverwritePolicy controls whether CopyDirWith replaces existing files.
+type OverwritePolicy int
+
+const (
+	// OverwriteAlways replaces existing files.
+	OverwriteAlways OverwritePolicy = iota
+	// OverwriteNever keeps existing files.
+	OverwriteNever
+	// OverwriteIfNewer replaces existing files older than the source.
+	OverwriteIfNewer
+	// OverwriteIfDifferent replaces existing files whose size or SHA-256
+	// hash of content differs from the source.
+	OverwriteIfDifferent
+)
+
+// CopyProgress is reported by CopyDirWith after each file is processed.
+type CopyProgress struct {
+	// Path is relative to the source directory with slash separator.
+	Path string
+	// Skipped is true if the file is kept by the overwrite policy.
+	Skipped bool
+	// Files and Bytes count processed files including skipped ones,
+	// TotalFiles and TotalBytes count all files to process.
+	Files, TotalFiles int
+	Bytes, TotalBytes int64
+}
+
+// CopyDirOptions controls how CopyDirWith copies files.
+type CopyDirOptions struct {
+	// Filter returns true for paths to skip, directories have suffix '/'.
+	// Content of filtered directories is still read, use Exclude to prune them.
+	Filter func(filePath string) bool
+	// Exclude skips entries and content of directories it returns true,
+	// e.g. Exclude of GitIgnore.
+	Exclude func(e *WalkEntry) bool
+	// Symlinks is the policy of symbolic links, SymlinkAsFile recreates
+	// links and SymlinkFollow copies content of their targets.
+	Symlinks SymlinkPolicy
+	// Merge copies into existing target directory instead of returning error.
+	Merge bool
+	// Overwrite is the policy of existing files when merging,
+	// default is OverwriteAlways.
+	Overwrite OverwritePolicy
+	// PreserveDirs sets modes and modification times of directories
+	// like source, files always keep them.
+	PreserveDirs bool
+	// PreserveOwner sets owner and group of files and directories
+	// like source, which usually requires privileges.
+	PreserveOwner bool
+	// DryRun reports progress without changing anything.
+	DryRun bool
+	// Progress is called after each file is copied or skipped.
+	Progress func(p CopyProgress)
+}
+
 // CopyDir copy files recursively from source to target directory.
 //
 // The filter accepts a function that process the path info.
-// and should return true for need to filter.
+// and should return true for need to filter, e.g. Filter of GitIgnore.
 //
 // It returns error when error occurs in underlying functions.
 func CopyDir(srcPath, destPath string, filters ...func(filePath string) bool) error {
+	var opts CopyDirOptions
+	if len(filters) > 0 {
+		opts.Filter = filters[0]
+	}
+	return CopyDirWith(srcPath, destPath, opts)
+}
+
+// CopyDirWith copy files recursively from source to target directory with options.
+// Directory modes and times are set after their content is copied, so
+// read-only directories can be copied as well.
+func CopyDirWith(srcPath, destPath string, opts CopyDirOptions) error {
 	// Check if target directory exists.
-	if IsExist(destPath) {
+	if !opts.Merge && IsExist(destPath) {
 		return errors.New("file or directory alreay exists: " + destPath)
 	}
-
-	err := os.MkdirAll(destPath, os.ModePerm)
-	if err != nil {
-		return err
+	root, err := os.Stat(srcPath)
+	if err != nil || !root.IsDir() {
+		return errors.New("not a directory or does not exist: " + srcPath)
 	}
 
 	// Gather directory info.
-	infos, err := StatDir(srcPath, true)
+	walkOpts := WalkOptions{
+		Symlinks: opts.Symlinks,
+		Exclude:  isDSStore,
+	}
+	if opts.Exclude != nil {
+		walkOpts.Exclude = func(e *WalkEntry) bool {
+			return isDSStore(e) || opts.Exclude(e)
+		}
+	}
+	var entries []*WalkEntry
+	err = Walk(srcPath, walkOpts, func(e *WalkEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := e.Path
+		if e.IsDir() {
+			name += "/"
+		}
+		if opts.Filter == nil || !opts.Filter(name) {
+			entries = append(entries, e)
+		}
+		return nil
+	})
 	if err != nil {
 		return err
 	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Path < entries[j].Path
+	})
 
-	var filter func(filePath string) bool
-	if len(filters) > 0 {
-		filter = filters[0]
+	var p CopyProgress
+	for _, e := range entries {
+		if !e.IsDir() {
+			p.TotalFiles++
+			p.TotalBytes += e.Info.Size()
+		}
 	}
 
-	for _, info := range infos {
-		if filter != nil && filter(info) {
-			continue
+	if !opts.DryRun {
+		if err = os.MkdirAll(destPath, os.ModePerm); err != nil {
+			return err
 		}
+	}
 
-		curPath := path.Join(destPath, info)
-		if strings.HasSuffix(info, "/") {
-			err = os.MkdirAll(curPath, os.ModePerm)
-		} else {
-			err = Copy(path.Join(srcPath, info), curPath)
+	dirs := []*WalkEntry{{FullPath: srcPath, Info: root}}
+	for _, e := range entries {
+		curPath := filepath.Join(destPath, filepath.FromSlash(e.Path))
+		if e.IsDir() {
+			dirs = append(dirs, e)
+			if !opts.DryRun {
+				if err = os.MkdirAll(curPath, os.ModePerm); err != nil {
+					return err
+				}
+			}
+			continue
 		}
+
+		copied, err := copyDirFile(e, curPath, &opts)
 		if err != nil {
 			return err
 		}
+		p.Path = e.Path
+		p.Skipped = !copied
+		p.Files++
+		p.Bytes += e.Info.Size()
+		if opts.Progress != nil {
+			opts.Progress(p)
+		}
+	}
+
+	if opts.DryRun || !opts.PreserveDirs && !opts.PreserveOwner {
+		return nil
+	}
+	// Content goes first, since copying files changes times of directories.
+	for i := len(dirs) - 1; i >= 0; i-- {
+		curPath := filepath.Join(destPath, filepath.FromSlash(dirs[i].Path))
+		if err = setDirInfo(curPath, dirs[i].Info, &opts); err != nil {
+			return err
+		}
 	}
 	return nil
 }
+
+// copyDirFile copies the file of e to dest by options, it returns
+// false if existing dest is kept by the overwrite policy.
+func copyDirFile(e *WalkEntry, dest string, opts *CopyDirOptions) (bool, error) {
+	di, err := os.Lstat(dest)
+	if err == nil {
+		ok, err := shouldOverwrite(e, dest, di, opts.Overwrite)
+		if err != nil || !ok {
+			return false, err
+		}
+		// Remove first to replace read-only files and symbolic links
+		// rather than writing through them.
+		if !opts.DryRun {
+			if err = os.Remove(dest); err != nil {
+				return false, err
+			}
+		}
+	} else if !os.IsNotExist(err) {
+		return false, err
+	}
+	if opts.DryRun {
+		return true, nil
+	}
+
+	// Info of followed links is of their targets, broken links are recreated.
+	if e.Info.Mode()&os.ModeSymlink != 0 {
+		err = Copy(e.FullPath, dest)
+	} else {
+		err = copyFile(e.FullPath, dest, e.Info)
+	}
+	if err == nil && opts.PreserveOwner {
+		err = chownLike(dest, e.Info)
+	}
+	return err == nil, err
+}
+
+// shouldOverwrite reports whether existing dest of info di should be
+// replaced by the file of e.
+func shouldOverwrite(e *WalkEntry, dest string, di os.FileInfo, policy OverwritePolicy) (bool, error) {
+	switch policy {
+	case OverwriteNever:
+		return false, nil
+	case OverwriteIfNewer:
+		return e.Info.ModTime().After(di.ModTime()), nil
+	case OverwriteIfDifferent:
+		if e.Info.Mode()&os.ModeType != di.Mode()&os.ModeType || e.Info.Size() != di.Size() {
+			return true, nil
+		}
+		if di.Mode()&os.ModeSymlink != 0 {
+			st, err := os.Readlink(e.FullPath)
+			if err != nil {
+				return false, err
+			}
+			dt, err := os.Readlink(dest)
+			return st != dt, err
+		}
+		sh, err := hashFile(e.FullPath)
+		if err != nil {
+			return false, err
+		}
+		dh, err := hashFile(dest)
+		return !bytes.Equal(sh, dh), err
+	}
+	return true, nil
+}
+
+// hashFile returns SHA-256 hash of content of the file.
+func hashFile(name string) ([]byte, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// setDirInfo sets back information of si to the directory by options.
+func setDirInfo(dir string, si os.FileInfo, opts *CopyDirOptions) error {
+	if opts.PreserveOwner {
+		if err := chownLike(dir, si); err != nil {
+			return err
+		}
+	}
+	if !opts.PreserveDirs {
+		return nil
+	}
+	if err := os.Chmod(dir, si.Mode()); err != nil {
+		return err
+	}
+	return os.Chtimes(dir, si.ModTime(), si.ModTime())
+}
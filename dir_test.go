@@ -15,8 +15,15 @@
 package com
 
 import (
+	"context"
+	"errors"
 	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -49,6 +56,336 @@ func TestCopyDir(t *testing.T) {
 	})
 }
 
+// makeWalkTree creates files of given slash paths under a temporary directory.
+func makeWalkTree(t *testing.T, files ...string) string {
+	root := t.TempDir()
+	for _, name := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// walkPaths returns sorted paths visited by Walk, directories have suffix '/'.
+func walkPaths(root string, opts WalkOptions, fn WalkFunc) ([]string, error) {
+	var paths []string
+	err := Walk(root, opts, func(e *WalkEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if e.IsDir() {
+			paths = append(paths, e.Path+"/")
+		} else {
+			paths = append(paths, e.Path)
+		}
+		if fn != nil {
+			return fn(e, nil)
+		}
+		return nil
+	})
+	sort.Strings(paths)
+	return paths, err
+}
+
+func TestWalk(t *testing.T) {
+	root := makeWalkTree(t, "a.go", "b.txt", "sub/c.go", "sub/deep/d.go", "vendor/e.go", "vendor/x/f.go")
+
+	Convey("Walk all entries concurrently", t, func() {
+		for _, workers := range []int{1, 4} {
+			paths, err := walkPaths(root, WalkOptions{Workers: workers}, nil)
+			So(err, ShouldBeNil)
+			So(paths, ShouldResemble, []string{
+				"a.go", "b.txt", "sub/", "sub/c.go", "sub/deep/", "sub/deep/d.go",
+				"vendor/", "vendor/e.go", "vendor/x/", "vendor/x/f.go",
+			})
+		}
+	})
+
+	Convey("Skip directories and limit depth", t, func() {
+		paths, err := walkPaths(root, WalkOptions{}, func(e *WalkEntry, _ error) error {
+			if e.Path == "vendor" {
+				return SkipDir
+			}
+			return nil
+		})
+		So(err, ShouldBeNil)
+		So(paths, ShouldResemble, []string{"a.go", "b.txt", "sub/", "sub/c.go", "sub/deep/", "sub/deep/d.go", "vendor/"})
+
+		paths, err = walkPaths(root, WalkOptions{MaxDepth: 2}, nil)
+		So(err, ShouldBeNil)
+		So(paths, ShouldResemble, []string{"a.go", "b.txt", "sub/", "sub/c.go", "sub/deep/", "vendor/", "vendor/e.go", "vendor/x/"})
+	})
+
+	Convey("Filter entries with include and exclude", t, func() {
+		opts := WalkOptions{
+			Include: func(e *WalkEntry) bool { return strings.HasSuffix(e.Path, ".go") },
+			Exclude: func(e *WalkEntry) bool { return e.Path == "vendor" },
+		}
+		paths, err := walkPaths(root, opts, nil)
+		So(err, ShouldBeNil)
+		So(paths, ShouldResemble, []string{"a.go", "sub/c.go", "sub/deep/d.go"})
+	})
+
+	Convey("Stop walk on error", t, func() {
+		errStop := errors.New("stop")
+		n := 0
+		_, err := walkPaths(root, WalkOptions{}, func(e *WalkEntry, _ error) error {
+			n++
+			return errStop
+		})
+		So(err, ShouldEqual, errStop)
+		So(n, ShouldEqual, 1)
+
+		_, err = walkPaths(filepath.Join(root, "a.go"), WalkOptions{}, nil)
+		So(err, ShouldNotBeNil)
+		_, err = walkPaths(filepath.Join(root, "404"), WalkOptions{}, nil)
+		So(os.IsNotExist(err), ShouldBeTrue)
+	})
+
+	Convey("Stream entries through channel", t, func() {
+		var paths []string
+		for r := range WalkChan(context.Background(), root, WalkOptions{Include: func(e *WalkEntry) bool { return !e.IsDir() }}) {
+			So(r.Err, ShouldBeNil)
+			So(r.Entry.FullPath, ShouldEqual, filepath.Join(root, filepath.FromSlash(r.Entry.Path)))
+			paths = append(paths, r.Entry.Path)
+		}
+		sort.Strings(paths)
+		So(paths, ShouldResemble, []string{"a.go", "b.txt", "sub/c.go", "sub/deep/d.go", "vendor/e.go", "vendor/x/f.go"})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		results := WalkChan(ctx, root, WalkOptions{})
+		<-results
+		cancel()
+		for range results {
+		}
+	})
+}
+
+func TestStatDir(t *testing.T) {
+	Convey("Gather sorted file list of directory", t, func() {
+		files, err := StatDir("testdata/statDir")
+		So(err, ShouldBeNil)
+		So(files, ShouldResemble, []string{
+			"SaveFile.txt", "SaveFileS.txt", "sample_file.txt",
+			"secondLevel/SaveFile.txt", "secondLevel/SaveFileS.txt", "secondLevel/sample_file.txt",
+		})
+
+		files, err = StatDir("testdata/statDir", true)
+		So(err, ShouldBeNil)
+		So(files, ShouldHaveLength, 7)
+		So(files[3], ShouldEqual, "secondLevel/")
+
+		dirs, err := GetAllSubDirs("testdata/statDir")
+		So(err, ShouldBeNil)
+		So(dirs, ShouldResemble, []string{"secondLevel/"})
+
+		_, err = StatDir("testdata/sample_file.txt")
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Skip .DS_Store files", t, func() {
+		root := makeWalkTree(t, "a.txt", ".DS_Store", "sub/.DS_Store")
+		files, err := StatDir(root, true)
+		So(err, ShouldBeNil)
+		So(files, ShouldResemble, []string{"a.txt", "sub/"})
+	})
+
+	Convey("Follow symbolic links", t, func() {
+		if runtime.GOOS == "windows" {
+			return
+		}
+
+		root := makeWalkTree(t, "real/a.txt")
+		So(os.Symlink(filepath.Join(root, "real"), filepath.Join(root, "link")), ShouldBeNil)
+
+		files, err := StatDir(root)
+		So(err, ShouldBeNil)
+		So(files, ShouldResemble, []string{"link", "real/a.txt"})
+
+		files, err = LstatDir(root)
+		So(err, ShouldBeNil)
+		So(files, ShouldResemble, []string{"link/a.txt", "real/a.txt"})
+
+		dirs, err := LgetAllSubDirs(root)
+		So(err, ShouldBeNil)
+		So(dirs, ShouldResemble, []string{"link/", "real/"})
+	})
+}
+
+func TestSymlinkPolicy(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("symbolic links need privilege on Windows")
+	}
+
+	root := makeWalkTree(t, "real/a.txt", "real/sub/b.txt")
+	symlink := func(target, name string) {
+		if err := os.Symlink(target, filepath.Join(root, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	symlink("real", "rel")          // Relative to directory of the link.
+	symlink("..", "real/sub/up")    // Loop to an ancestor.
+	symlink("a.txt", "real/a-link") // Link to file.
+	symlink("404", "broken")        // Broken link.
+
+	Convey("List symbolic links as files", t, func() {
+		files, err := StatDir(root)
+		So(err, ShouldBeNil)
+		So(files, ShouldResemble, []string{"broken", "real/a-link", "real/a.txt", "real/sub/b.txt", "real/sub/up", "rel"})
+	})
+
+	Convey("Follow symbolic links and stop at cycles", t, func() {
+		files, err := LstatDir(root, true)
+		So(err, ShouldBeNil)
+		So(files, ShouldResemble, []string{
+			"broken",
+			"real/", "real/a-link", "real/a.txt", "real/sub/", "real/sub/b.txt", "real/sub/up/",
+			"rel/", "rel/a-link", "rel/a.txt", "rel/sub/", "rel/sub/b.txt", "rel/sub/up/",
+		})
+
+		dirs, err := LgetAllSubDirs(root)
+		So(err, ShouldBeNil)
+		So(dirs, ShouldResemble, []string{"real/", "real/sub/", "real/sub/up/", "rel/", "rel/sub/", "rel/sub/up/"})
+	})
+
+	Convey("Skip symbolic links", t, func() {
+		files, err := StatDirWith(root, StatDirOptions{Symlinks: SymlinkSkip})
+		So(err, ShouldBeNil)
+		So(files, ShouldResemble, []string{"real/a.txt", "real/sub/b.txt"})
+	})
+
+	Convey("Copy directory with symbolic link policy", t, func() {
+		dest := filepath.Join(t.TempDir(), "links")
+		So(CopyDir(filepath.Join(root, "real"), dest), ShouldBeNil)
+		target, err := os.Readlink(filepath.Join(dest, "a-link"))
+		So(err, ShouldBeNil)
+		So(target, ShouldEqual, "a.txt")
+
+		dest = filepath.Join(t.TempDir(), "follow")
+		So(CopyDirWith(root, dest, CopyDirOptions{Symlinks: SymlinkFollow}), ShouldBeNil)
+		fi, err := os.Lstat(filepath.Join(dest, "rel/a-link"))
+		So(err, ShouldBeNil)
+		So(fi.Mode().IsRegular(), ShouldBeTrue)
+		data, err := os.ReadFile(filepath.Join(dest, "rel/sub/b.txt"))
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "real/sub/b.txt")
+		_, err = os.Readlink(filepath.Join(dest, "broken"))
+		So(err, ShouldBeNil)
+
+		dest = filepath.Join(t.TempDir(), "skip")
+		So(CopyDirWith(root, dest, CopyDirOptions{Symlinks: SymlinkSkip}), ShouldBeNil)
+		So(IsExist(filepath.Join(dest, "rel")), ShouldBeFalse)
+		So(IsFile(filepath.Join(dest, "real/a.txt")), ShouldBeTrue)
+	})
+}
+
+func TestCopyDirWith(t *testing.T) {
+	src := makeWalkTree(t, "a.txt", "b.txt", "sub/c.txt")
+	old := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	// newDest returns a target directory with outdated a.txt, newer
+	// b.txt of different content and c.txt of same content.
+	newDest := func() string {
+		dest := makeWalkTree(t, "sub/c.txt")
+		So(os.WriteFile(filepath.Join(dest, "a.txt"), []byte("old"), 0644), ShouldBeNil)
+		So(os.Chtimes(filepath.Join(dest, "a.txt"), old, old), ShouldBeNil)
+		So(os.WriteFile(filepath.Join(dest, "b.txt"), []byte("new"), 0644), ShouldBeNil)
+		So(os.Chtimes(filepath.Join(dest, "b.txt"), time.Now().Add(time.Hour), time.Now().Add(time.Hour)), ShouldBeNil)
+		return dest
+	}
+	// copied returns sorted paths of files that are copied with the options.
+	copied := func(dest string, opts CopyDirOptions) []string {
+		var paths []string
+		opts.Merge = true
+		opts.Progress = func(p CopyProgress) {
+			if !p.Skipped {
+				paths = append(paths, p.Path)
+			}
+		}
+		So(CopyDirWith(src, dest, opts), ShouldBeNil)
+		return paths
+	}
+
+	Convey("Refuse existing target unless merging", t, func() {
+		dest := newDest()
+		So(CopyDirWith(src, dest, CopyDirOptions{}), ShouldNotBeNil)
+		So(CopyDirWith(src, dest, CopyDirOptions{Merge: true}), ShouldBeNil)
+		data, err := os.ReadFile(filepath.Join(dest, "b.txt"))
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "b.txt")
+	})
+
+	Convey("Overwrite policies", t, func() {
+		So(copied(newDest(), CopyDirOptions{Overwrite: OverwriteAlways}), ShouldResemble, []string{"a.txt", "b.txt", "sub/c.txt"})
+		So(copied(newDest(), CopyDirOptions{Overwrite: OverwriteNever}), ShouldBeEmpty)
+		So(copied(newDest(), CopyDirOptions{Overwrite: OverwriteIfNewer}), ShouldResemble, []string{"a.txt"})
+		So(copied(newDest(), CopyDirOptions{Overwrite: OverwriteIfDifferent}), ShouldResemble, []string{"a.txt", "b.txt"})
+
+		dest := newDest()
+		copied(dest, CopyDirOptions{Overwrite: OverwriteNever})
+		data, err := os.ReadFile(filepath.Join(dest, "a.txt"))
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "old")
+	})
+
+	Convey("Dry run reports without changes", t, func() {
+		dest := newDest()
+		So(copied(dest, CopyDirOptions{Overwrite: OverwriteIfNewer, DryRun: true}), ShouldResemble, []string{"a.txt"})
+		data, err := os.ReadFile(filepath.Join(dest, "a.txt"))
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, "old")
+
+		dest = filepath.Join(t.TempDir(), "none")
+		So(copied(dest, CopyDirOptions{DryRun: true}), ShouldHaveLength, 3)
+		So(IsExist(dest), ShouldBeFalse)
+	})
+
+	Convey("Report progress with counts and bytes", t, func() {
+		var last CopyProgress
+		calls := 0
+		err := CopyDirWith(src, filepath.Join(t.TempDir(), "progress"), CopyDirOptions{
+			Progress: func(p CopyProgress) {
+				calls++
+				last = p
+			},
+		})
+		So(err, ShouldBeNil)
+		So(calls, ShouldEqual, 3)
+		So(last, ShouldResemble, CopyProgress{
+			Path:       "sub/c.txt",
+			Files:      3,
+			TotalFiles: 3,
+			Bytes:      int64(len("a.txt") + len("b.txt") + len("sub/c.txt")),
+			TotalBytes: int64(len("a.txt") + len("b.txt") + len("sub/c.txt")),
+		})
+	})
+
+	Convey("Preserve modes and times of directories", t, func() {
+		sub := filepath.Join(src, "sub")
+		So(os.Chtimes(sub, old, old), ShouldBeNil)
+		if runtime.GOOS != "windows" {
+			So(os.Chmod(sub, 0555), ShouldBeNil)
+			defer os.Chmod(sub, 0755)
+		}
+
+		dest := filepath.Join(t.TempDir(), "preserve")
+		So(CopyDirWith(src, dest, CopyDirOptions{PreserveDirs: true}), ShouldBeNil)
+		fi, err := os.Stat(filepath.Join(dest, "sub"))
+		So(err, ShouldBeNil)
+		So(fi.ModTime().Equal(old), ShouldBeTrue)
+		if runtime.GOOS != "windows" {
+			So(fi.Mode().Perm(), ShouldEqual, os.FileMode(0555))
+			So(os.Chmod(filepath.Join(dest, "sub"), 0755), ShouldBeNil)
+		}
+		So(IsFile(filepath.Join(dest, "sub/c.txt")), ShouldBeTrue)
+	})
+}
+
 func BenchmarkIsDir(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		IsDir("file.go")
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"os"
 
 	"github.com/unknwon/com"
 )
@@ -41,6 +42,14 @@ func ExampleColorLog() {
 		"path to somewhere", "highlighted content", "tesing error"))
 }
 
+func ExampleLogger() {
+	log := com.NewLogger(os.Stdout)
+	log.SetColor(false)
+	log.With("repo", "unknwon/com").Info("cloned", "files", 42)
+	log.Debug("not shown below minimum level")
+	// Output: [INFO] cloned repo=unknwon/com files=42
+}
+
 func ExampleExecCmd() {
 	stdout, stderr, err := com.ExecCmd("go", "help", "get")
 	fmt.Println(stdout, stderr, err)
@@ -15,12 +15,16 @@
 package com
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"math"
+	"math/big"
 	"os"
 	"path"
+	"strconv"
+	"strings"
 )
 
 // Storage unit constants.
@@ -59,6 +63,196 @@ func HumaneFileSize(s uint64) string {
 	return humanateBytes(s, 1024, sizes)
 }
 
+var (
+	siSizes  = []string{"B", "kB", "MB", "GB", "TB", "PB", "EB"}
+	iecSizes = []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+)
+
+// FileSizeFormat controls how FormatFileSize generates user-friendly string.
+type FileSizeFormat struct {
+	// SI uses powers of 1000 with kB, MB, ... instead of powers of 1024 with KiB, MiB, ...
+	SI bool
+	// Precision is the maximum number of digits after decimal point,
+	// trailing zeros are removed.
+	Precision int
+	// Sep is put between number and unit, e.g. " ".
+	Sep string
+}
+
+// FormatFileSize formats s with IEC units, precision of 1 and no separator, e.g. "1.5MiB".
+func FormatFileSize(s uint64) string {
+	return FileSizeFormat{Precision: 1}.Format(s)
+}
+
+// Format generates user-friendly string of size s, which can be parsed by ParseFileSize.
+func (f FileSizeFormat) Format(s uint64) string {
+	base, sizes := 1024.0, iecSizes
+	if f.SI {
+		base, sizes = 1000.0, siSizes
+	}
+	prec := f.Precision
+	if prec < 0 {
+		prec = 0
+	}
+
+	val, e := float64(s), 0
+	for val >= base && e < len(sizes)-1 {
+		val /= base
+		e++
+	}
+	// Rounding may carry over to the next unit, e.g. 1023.99KiB with precision 1.
+	pow := math.Pow10(prec)
+	if e > 0 && math.Round(val*pow)/pow >= base && e < len(sizes)-1 {
+		val /= base
+		e++
+	}
+	if e == 0 {
+		prec = 0
+	}
+
+	num := strconv.FormatFloat(val, 'f', prec, 64)
+	if strings.IndexByte(num, '.') >= 0 {
+		num = strings.TrimRight(strings.TrimRight(num, "0"), ".")
+	}
+	return num + f.Sep + sizes[e]
+}
+
+// ParseFileSize parses user-friendly string like "1.5MB", "512KiB", "2G" or "10 mb"
+// into bytes. SI units (k, kB, M, MB, ...) are powers of 1000 and IEC units
+// (Ki, KiB, Mi, MiB, ...) are powers of 1024, units are case-insensitive.
+// Number without unit or with "B" is bytes, fraction of a byte is truncated.
+// It returns *StrToError wrapping strconv.ErrSyntax for invalid input or
+// strconv.ErrRange when the value overflows uint64.
+//
+// Note that HumaneFileSize uses KB, MB, ... for powers of 1024, so its
+// output should be parsed by ParseHumaneFileSize instead.
+func ParseFileSize(s string) (uint64, error) {
+	return parseFileSize(s, 1000)
+}
+
+// ParseHumaneFileSize is like ParseFileSize but units without "i" are
+// powers of 1024 as well, so strings of HumaneFileSize like "1.5MB" are
+// parsed back to the size, up to rounding of HumaneFileSize.
+func ParseHumaneFileSize(s string) (uint64, error) {
+	return parseFileSize(s, 1024)
+}
+
+// parseFileSize parses s with siBase as base of units without "i".
+func parseFileSize(s string, siBase int64) (uint64, error) {
+	str := strings.TrimSpace(s)
+	i := 0
+	for i < len(str) && (str[i] >= '0' && str[i] <= '9' || str[i] == '.') {
+		i++
+	}
+	num, unit := str[:i], strings.ToLower(strings.TrimSpace(str[i:]))
+	if strings.Count(num, ".") > 1 || strings.Trim(num, ".") == "" {
+		return 0, StrTo(s).newError("file size", strconv.ErrSyntax)
+	}
+
+	if unit == "b" {
+		unit = ""
+	} else {
+		unit = strings.TrimSuffix(unit, "b")
+	}
+	base := siBase
+	if strings.HasSuffix(unit, "i") {
+		base, unit = 1024, strings.TrimSuffix(unit, "i")
+		if unit == "" {
+			return 0, StrTo(s).newError("file size", strconv.ErrSyntax)
+		}
+	}
+	exp := 0
+	if unit != "" {
+		exp = strings.Index("kmgtpe", unit) + 1
+		if len(unit) != 1 || exp == 0 {
+			return 0, StrTo(s).newError("file size", strconv.ErrSyntax)
+		}
+	}
+
+	r, ok := new(big.Rat).SetString(num)
+	if !ok {
+		return 0, StrTo(s).newError("file size", strconv.ErrSyntax)
+	}
+	mult := new(big.Int).Exp(big.NewInt(base), big.NewInt(int64(exp)), nil)
+	r.Mul(r, new(big.Rat).SetInt(mult))
+	n := new(big.Int).Quo(r.Num(), r.Denom())
+	if !n.IsUint64() {
+		return math.MaxUint64, StrTo(s).newError("file size", strconv.ErrRange)
+	}
+	return n.Uint64(), nil
+}
+
+// ByteSize is size in bytes, it can be set by string like "10MB" or "512KiB"
+// in flags, text and JSON, see ParseHumaneFileSize for details.
+//
+// Units are powers of 1024 like KByte, MByte, ... and HumaneFileSize,
+// so "10MB" is 10*MByte rather than 10,000,000 bytes of ParseFileSize.
+type ByteSize uint64
+
+// String returns user-friendly string by HumaneFileSize, e.g. "1.5MB",
+// or the exact size of MarshalText when it would be rounded, so that
+// Set parses it back to the same value, e.g. in defaults of flag.PrintDefaults.
+func (b ByteSize) String() string {
+	s := HumaneFileSize(uint64(b))
+	if n, err := ParseHumaneFileSize(s); err == nil && n == uint64(b) {
+		return s
+	}
+	text, _ := b.MarshalText()
+	return string(text)
+}
+
+// Set implements flag.Value.
+func (b *ByteSize) Set(s string) error {
+	n, err := ParseHumaneFileSize(s)
+	if err != nil {
+		return err
+	}
+	*b = ByteSize(n)
+	return nil
+}
+
+// MarshalText returns the exact size with the largest IEC unit
+// that divides it, e.g. "10MiB", "1536KiB" or "1500B".
+func (b ByteSize) MarshalText() ([]byte, error) {
+	n := uint64(b)
+	num, unit := n, "B"
+	for e := len(iecSizes) - 1; e > 0 && n > 0; e-- {
+		if iec := uint64(1) << (10 * e); n%iec == 0 {
+			num, unit = n/iec, iecSizes[e]
+			break
+		}
+	}
+	return []byte(strconv.FormatUint(num, 10) + unit), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	return b.Set(string(text))
+}
+
+// MarshalJSON encodes size as string of MarshalText.
+func (b ByteSize) MarshalJSON() ([]byte, error) {
+	text, _ := b.MarshalText()
+	return json.Marshal(string(text))
+}
+
+// UnmarshalJSON accepts both string and number of bytes.
+func (b *ByteSize) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+
+	var s string
+	if len(data) > 0 && data[0] == '"' {
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+	} else {
+		s = string(data)
+	}
+	return b.Set(s)
+}
+
 // FileMTime returns file modified time and possible error.
 func FileMTime(file string) (int64, error) {
 	f, err := os.Stat(file)
@@ -95,7 +289,11 @@ func Copy(src, dest string) error {
 		// which will lead "no such file or directory" error.
 		return os.Symlink(target, dest)
 	}
+	return copyFile(src, dest, si)
+}
 
+// copyFile copies content of src to dest and sets back information of si.
+func copyFile(src, dest string, si os.FileInfo) error {
 	sr, err := os.Open(src)
 	if err != nil {
 		return err
@@ -15,6 +15,12 @@
 package com
 
 import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"strconv"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -48,6 +54,190 @@ func TestIsExist(t *testing.T) {
 	})
 }
 
+func TestHumaneFileSize(t *testing.T) {
+	Convey("Generate user-friendly file size", t, func() {
+		So(HumaneFileSize(5), ShouldEqual, "5B")
+		So(HumaneFileSize(1536*KByte), ShouldEqual, "1.5MB")
+	})
+}
+
+func TestFormatFileSize(t *testing.T) {
+	Convey("Format file size with IEC units", t, func() {
+		So(FormatFileSize(0), ShouldEqual, "0B")
+		So(FormatFileSize(1023), ShouldEqual, "1023B")
+		So(FormatFileSize(1024), ShouldEqual, "1KiB")
+		So(FormatFileSize(1536*KByte), ShouldEqual, "1.5MiB")
+		So(FormatFileSize(MByte-1), ShouldEqual, "1MiB")
+		So(FormatFileSize(math.MaxUint64), ShouldEqual, "16EiB")
+	})
+
+	Convey("Format file size with options", t, func() {
+		f := FileSizeFormat{SI: true, Precision: 2, Sep: " "}
+		So(f.Format(999), ShouldEqual, "999 B")
+		So(f.Format(1234567), ShouldEqual, "1.23 MB")
+		So(f.Format(1500000000), ShouldEqual, "1.5 GB")
+		So(FileSizeFormat{SI: true}.Format(1500), ShouldEqual, "2kB")
+	})
+
+	Convey("Round trip with ParseFileSize", t, func() {
+		for _, n := range []uint64{0, 1, 1000, 1024, 1536 * KByte, 2 * GByte, 3 * EByte} {
+			v, err := ParseFileSize(FormatFileSize(n))
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, n)
+		}
+
+		f := FileSizeFormat{SI: true, Precision: 3, Sep: " "}
+		for _, n := range []uint64{0, 999, 1500, 2000000000, 3000000000000000000} {
+			v, err := ParseFileSize(f.Format(n))
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, n)
+		}
+	})
+}
+
+func TestParseFileSize(t *testing.T) {
+	Convey("Parse user-friendly file size", t, func() {
+		sizes := map[string]uint64{
+			"0":        0,
+			"42":       42,
+			"42B":      42,
+			"1.5MB":    1500000,
+			"1.5 MiB":  1536 * KByte,
+			"512KiB":   512 * KByte,
+			"512kib":   512 * KByte,
+			"2G":       2000000000,
+			"2Gi":      2 * GByte,
+			"10 mb":    10000000,
+			" 1kB ":    1000,
+			".5k":      500,
+			"1.0009kB": 1000,
+		}
+
+		for s, n := range sizes {
+			v, err := ParseFileSize(s)
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, n)
+		}
+	})
+
+	Convey("Reject invalid and overflowed file size", t, func() {
+		for _, s := range []string{"", "MB", "-1MB", "1.2.3", "1 KX", "1ib", "1bb", "1 k b"} {
+			_, err := ParseFileSize(s)
+			So(errors.Is(err, strconv.ErrSyntax), ShouldBeTrue)
+		}
+
+		_, err := ParseFileSize("16EiB")
+		So(errors.Is(err, strconv.ErrRange), ShouldBeTrue)
+		So(err.Error(), ShouldEqual, `cannot convert "16EiB" to file size: value out of range`)
+
+		v, err := ParseFileSize("15.9999EiB")
+		So(err, ShouldBeNil)
+		So(v, ShouldBeGreaterThan, uint64(15*EByte))
+	})
+}
+
+func TestParseHumaneFileSize(t *testing.T) {
+	Convey("Round trip with HumaneFileSize", t, func() {
+		for _, n := range []uint64{0, 1, 1023, KByte, 1536, 1536 * KByte, 5 * GByte, 2 * EByte} {
+			v, err := ParseHumaneFileSize(HumaneFileSize(n))
+			So(err, ShouldBeNil)
+			So(v, ShouldEqual, n)
+		}
+
+		// Powers of 1024 with one decimal are kept up to rounding.
+		v, err := ParseHumaneFileSize(HumaneFileSize(1234567))
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, uint64(12*MByte/10))
+	})
+
+	Convey("Units with i and invalid input", t, func() {
+		v, err := ParseHumaneFileSize("1.5MiB")
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, 1536*KByte)
+		v, err = ParseHumaneFileSize("2 kb")
+		So(err, ShouldBeNil)
+		So(v, ShouldEqual, 2*KByte)
+
+		_, err = ParseHumaneFileSize("1 KX")
+		So(errors.Is(err, strconv.ErrSyntax), ShouldBeTrue)
+		_, err = ParseHumaneFileSize("16EB")
+		So(errors.Is(err, strconv.ErrRange), ShouldBeTrue)
+	})
+}
+
+func TestByteSize(t *testing.T) {
+	Convey("Format byte size", t, func() {
+		So(ByteSize(1536*KByte).String(), ShouldEqual, HumaneFileSize(1536*KByte))
+		So(ByteSize(MByte).String(), ShouldEqual, HumaneFileSize(MByte))
+		So(fmt.Sprint(ByteSize(5)), ShouldEqual, "5B")
+
+		// Rounded strings fall back to the exact size.
+		So(ByteSize(1234567).String(), ShouldEqual, "1234567B")
+		So(ByteSize(10*MByte+KByte).String(), ShouldEqual, "10241KiB")
+	})
+
+	Convey("Set parses String back to the same value", t, func() {
+		for _, b := range []ByteSize{0, 5, 1000, MByte, 1536 * KByte, 1234567, 10 * 1000 * 1000, 8 * EByte, math.MaxUint64} {
+			var v ByteSize
+			So(v.Set(b.String()), ShouldBeNil)
+			So(v, ShouldEqual, b)
+		}
+	})
+
+	Convey("Marshal and unmarshal byte size as text", t, func() {
+		sizes := map[ByteSize]string{
+			0:                  "0B",
+			1500:               "1500B",
+			10 * 1000 * 1000:   "10000000B",
+			10 * MByte:         "10MiB",
+			512 * KByte:        "512KiB",
+			1536 * KByte:       "1536KiB",
+			8 * EByte:          "8EiB",
+			1024 * 1000 * 1000: "1000000KiB",
+		}
+		for b, s := range sizes {
+			text, err := b.MarshalText()
+			So(err, ShouldBeNil)
+			So(string(text), ShouldEqual, s)
+
+			var v ByteSize
+			So(v.UnmarshalText(text), ShouldBeNil)
+			So(v, ShouldEqual, b)
+		}
+
+		var v ByteSize
+		So(v.UnmarshalText([]byte("1 foo")), ShouldNotBeNil)
+	})
+
+	Convey("Marshal and unmarshal byte size as JSON", t, func() {
+		var cfg struct {
+			MaxUpload ByteSize
+			MaxBody   ByteSize
+			Limit     *ByteSize
+		}
+		So(json.Unmarshal([]byte(`{"MaxUpload": "10MB", "MaxBody": 2048, "Limit": null}`), &cfg), ShouldBeNil)
+		So(cfg.MaxUpload, ShouldEqual, 10*MByte)
+		So(cfg.MaxBody, ShouldEqual, 2048)
+		So(cfg.Limit, ShouldBeNil)
+
+		data, err := json.Marshal(cfg)
+		So(err, ShouldBeNil)
+		So(string(data), ShouldEqual, `{"MaxUpload":"10MiB","MaxBody":"2KiB","Limit":null}`)
+
+		So(json.Unmarshal([]byte(`{"MaxUpload": true}`), &cfg), ShouldNotBeNil)
+	})
+
+	Convey("Set byte size by flag", t, func() {
+		var size ByteSize
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		fs.Var(&size, "max", "max size")
+		So(fs.Parse([]string{"-max", "2GiB"}), ShouldBeNil)
+		So(size, ShouldEqual, 2*GByte)
+		So(fs.Parse([]string{"-max", "1MB"}), ShouldBeNil)
+		So(size, ShouldEqual, MByte)
+	})
+}
+
 func BenchmarkIsFile(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		IsFile("file.go")
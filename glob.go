@@ -0,0 +1,262 @@
+// Copyright 2014 com authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package com
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// Glob is a compiled glob pattern which matches slash separated paths.
+//
+// The pattern syntax is:
+//
+//	?        matches any single non-separator character
+//	*        matches any sequence of non-separator characters
+//	**       as a whole path segment matches zero or more directories
+//	[abc]    matches any character in the class, also [a-z], [!a] and [^a]
+//	{a,b}    matches any of comma separated alternatives, which can be nested
+//	\c       matches character c
+//
+// Pattern with "!" prefix is negated.
+type Glob struct {
+	pattern string
+	negate  bool
+	re      *regexp.Regexp
+}
+
+// CompileGlob parses a glob pattern, it returns path.ErrBadPattern
+// when the pattern is malformed.
+func CompileGlob(pattern string) (*Glob, error) {
+	g := &Glob{pattern: pattern}
+	if strings.HasPrefix(pattern, "!") {
+		g.negate, pattern = true, pattern[1:]
+	}
+
+	expr, err := globToRegexp(pattern)
+	if err != nil {
+		return nil, err
+	}
+	g.re, err = regexp.Compile(expr)
+	if err != nil {
+		return nil, path.ErrBadPattern
+	}
+	return g, nil
+}
+
+// MustCompileGlob is like CompileGlob but panics if the pattern is malformed.
+func MustCompileGlob(pattern string) *Glob {
+	g, err := CompileGlob(pattern)
+	if err != nil {
+		panic("glob: Compile(" + pattern + "): " + err.Error())
+	}
+	return g
+}
+
+// String returns the source pattern.
+func (g *Glob) String() string {
+	return g.pattern
+}
+
+// Negated returns true if the pattern has "!" prefix.
+func (g *Glob) Negated() bool {
+	return g.negate
+}
+
+// Match reports whether name matches the pattern, the result is
+// inverted for negated pattern.
+func (g *Glob) Match(name string) bool {
+	return g.re.MatchString(name) != g.negate
+}
+
+// MatchGlob reports whether name matches the glob pattern.
+func MatchGlob(pattern, name string) (bool, error) {
+	g, err := CompileGlob(pattern)
+	if err != nil {
+		return false, err
+	}
+	return g.Match(name), nil
+}
+
+// globToRegexp translates glob pattern to anchored regular expression.
+func globToRegexp(pattern string) (string, error) {
+	var buf strings.Builder
+	buf.WriteByte('^')
+
+	depth := 0 // Depth of braces.
+	isSegmentBound := func(i int) bool {
+		if i < 0 || i >= len(pattern) {
+			return true
+		}
+		c := pattern[i]
+		return c == '/' || depth > 0 && (c == '{' || c == ',' || c == '}')
+	}
+
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '\\':
+			if i++; i >= len(pattern) {
+				return "", path.ErrBadPattern
+			}
+			buf.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		case '*':
+			if i+1 >= len(pattern) || pattern[i+1] != '*' {
+				buf.WriteString("[^/]*")
+				break
+			}
+			if !isSegmentBound(i-1) || !isSegmentBound(i+2) {
+				buf.WriteString("[^/]*")
+				i++
+				break
+			}
+			if i+2 < len(pattern) && pattern[i+2] == '/' {
+				buf.WriteString("(?:.*/)?")
+				i += 2
+			} else {
+				buf.WriteString(".*")
+				i++
+			}
+		case '?':
+			buf.WriteString("[^/]")
+		case '[':
+			j := i + 1
+			negate := j < len(pattern) && (pattern[j] == '!' || pattern[j] == '^')
+			if negate {
+				j++
+			}
+			buf.WriteByte('[')
+			if negate {
+				buf.WriteString("^/")
+			}
+			for start := j; ; j++ {
+				if j >= len(pattern) {
+					return "", path.ErrBadPattern
+				}
+				c := pattern[j]
+				if c == ']' && j > start {
+					break
+				}
+				if c == '\\' {
+					if j++; j >= len(pattern) {
+						return "", path.ErrBadPattern
+					}
+					c = pattern[j]
+				}
+				if c == '-' && j > start && j+1 < len(pattern) && pattern[j+1] != ']' {
+					buf.WriteByte('-')
+				} else if c < 0x80 && !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+					buf.WriteByte('\\')
+					buf.WriteByte(c)
+				} else {
+					buf.WriteByte(c)
+				}
+			}
+			buf.WriteByte(']')
+			i = j
+		case '{':
+			depth++
+			buf.WriteString("(?:")
+		case ',':
+			if depth > 0 {
+				buf.WriteByte('|')
+			} else {
+				buf.WriteByte(',')
+			}
+		case '}':
+			if depth > 0 {
+				depth--
+				buf.WriteByte(')')
+			} else {
+				buf.WriteString(`\}`)
+			}
+		default:
+			buf.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+		}
+	}
+	if depth > 0 {
+		return "", path.ErrBadPattern
+	}
+
+	buf.WriteByte('$')
+	return buf.String(), nil
+}
+
+// GlobSet matches paths against many glob patterns, a path matches when it
+// matches any pattern without "!" prefix and none of negated patterns.
+// When all patterns are negated, paths match unless they are excluded.
+type GlobSet struct {
+	include []*Glob
+	exclude []*Glob
+}
+
+// CompileGlobSet parses all patterns.
+func CompileGlobSet(patterns ...string) (*GlobSet, error) {
+	s := new(GlobSet)
+	for _, p := range patterns {
+		g, err := CompileGlob(p)
+		if err != nil {
+			return nil, err
+		}
+		if g.negate {
+			s.exclude = append(s.exclude, g)
+		} else {
+			s.include = append(s.include, g)
+		}
+	}
+	return s, nil
+}
+
+// Match reports whether name matches the set.
+func (s *GlobSet) Match(name string) bool {
+	for _, g := range s.exclude {
+		if !g.Match(name) {
+			return false
+		}
+	}
+	if len(s.include) == 0 {
+		return true
+	}
+	for _, g := range s.include {
+		if g.Match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// GlobDir returns sorted slash separated paths of files under given
+// directory that match the patterns, see GlobSet for details.
+// Paths are relative to given directory like StatDir,
+// e.g. GlobDir("static", "**/*.{js,css}", "!**/*.min.js").
+func GlobDir(rootPath string, patterns ...string) ([]string, error) {
+	set, err := CompileGlobSet(patterns...)
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := StatDir(rootPath)
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make([]string, 0, len(files))
+	for _, f := range files {
+		if set.Match(f) {
+			matched = append(matched, f)
+		}
+	}
+	return matched, nil
+}
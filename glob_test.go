@@ -0,0 +1,101 @@
+// Copyright 2014 com authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package com
+
+import (
+	"path"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestMatchGlob(t *testing.T) {
+	Convey("Match paths against glob patterns", t, func() {
+		cases := []struct {
+			pattern string
+			name    string
+			match   bool
+		}{
+			{"*.go", "a.go", true},
+			{"*.go", "sub/a.go", false},
+			{"a?c", "abc", true},
+			{"a?c", "a/c", false},
+			{"**", "a/b/c", true},
+			{"**/*.go", "a.go", true},
+			{"**/*.go", "a/b/c.go", true},
+			{"a/**/b", "a/b", true},
+			{"a/**/b", "a/x/y/b", true},
+			{"a/**/b", "ab", false},
+			{"a/**", "a/x/y", true},
+			{"a**b", "axxb", true},
+			{"a**b", "ax/xb", false},
+			{"static/**/*.{js,css}", "static/app.js", true},
+			{"static/**/*.{js,css}", "static/lib/x/app.css", true},
+			{"static/**/*.{js,css}", "static/app.html", false},
+			{"{a,b{c,d}}.txt", "bd.txt", true},
+			{"{a,b{c,d}}.txt", "b.txt", false},
+			{"{**/,}x", "x", true},
+			{"{**/,}x", "a/b/x", true},
+			{"[a-c]x", "bx", true},
+			{"[a-c]x", "dx", false},
+			{"[!a-c]x", "dx", true},
+			{"[^a-c]x", "/x", false},
+			{"[]]", "]", true},
+			{"[.-]", "-", true},
+			{`\*`, "*", true},
+			{`\*`, "a", false},
+			{"a.b", "axb", false},
+			{"a,b", "a,b", true},
+			{"!*.go", "a.go", false},
+			{"!*.go", "a.txt", true},
+		}
+		for _, c := range cases {
+			ok, err := MatchGlob(c.pattern, c.name)
+			So(err, ShouldBeNil)
+			So(ok, ShouldEqual, c.match)
+		}
+	})
+
+	Convey("Reject malformed patterns", t, func() {
+		for _, pattern := range []string{"[a", "{a,b", `a\`, "[]"} {
+			_, err := CompileGlob(pattern)
+			So(err, ShouldEqual, path.ErrBadPattern)
+		}
+		So(func() { MustCompileGlob("{") }, ShouldPanic)
+	})
+}
+
+func TestGlobDir(t *testing.T) {
+	root := makeWalkTree(t,
+		"index.html", "static/app.js", "static/app.min.js", "static/css/site.css",
+		"static/img/logo.png", "vendor/lib.js")
+
+	Convey("List files matching glob patterns", t, func() {
+		files, err := GlobDir(root, "static/**/*.{js,css}")
+		So(err, ShouldBeNil)
+		So(files, ShouldResemble, []string{"static/app.js", "static/app.min.js", "static/css/site.css"})
+
+		files, err = GlobDir(root, "**/*.js", "!**/*.min.js", "!vendor/**")
+		So(err, ShouldBeNil)
+		So(files, ShouldResemble, []string{"static/app.js"})
+
+		files, err = GlobDir(root, "!static/**")
+		So(err, ShouldBeNil)
+		So(files, ShouldResemble, []string{"index.html", "vendor/lib.js"})
+
+		_, err = GlobDir(root, "{")
+		So(err, ShouldNotBeNil)
+	})
+}
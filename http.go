@@ -177,25 +177,29 @@ func FetchFiles(client *http.Client, files []RawFile, header http.Header) error
 	return nil
 }
 
+// FetchFilesCurlParallel is the maximum number of `curl` commands
+// run at the same time by FetchFilesCurl.
+var FetchFilesCurlParallel = 8
+
 // FetchFilesCurl uses command `curl` to fetch files specified by the rawURL field in parallel.
 func FetchFilesCurl(files []RawFile, curlOptions ...string) error {
-	ch := make(chan error, len(files))
-	for i := range files {
-		go func(i int) {
-			stdout, _, err := ExecCmd("curl", append(curlOptions, files[i].RawUrl())...)
-			if err != nil {
-				ch <- err
-				return
-			}
+	if _, err := LookPath("curl"); err != nil {
+		return err
+	}
 
-			files[i].SetData([]byte(stdout))
-			ch <- nil
-		}(i)
+	cmds := make([]*Cmd, len(files))
+	for i := range files {
+		args := make([]string, 0, len(curlOptions)+1)
+		args = append(args, curlOptions...)
+		cmds[i] = NewCmd("curl", append(args, files[i].RawUrl())...)
 	}
-	for _ = range files {
-		if err := <-ch; err != nil {
-			return err
+
+	runner := &CmdRunner{Parallel: FetchFilesCurlParallel}
+	results, err := runner.Run(cmds...)
+	for i, r := range results {
+		if r != nil && r.Success() {
+			files[i].SetData(r.Stdout)
 		}
 	}
-	return nil
+	return err
 }
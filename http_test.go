@@ -15,8 +15,10 @@
 package com
 
 import (
+	"bytes"
 	"io/ioutil"
 	"net/http"
+	"path/filepath"
 	"strings"
 	"testing"
 )
@@ -109,3 +111,25 @@ func TestFetchFilesCurl(t *testing.T) {
 		t.Errorf("FetchFilesCurl:\n Expect => %d\n Got => %d\n", 1270, len(files[1].Data()))
 	}
 }
+
+func TestFetchFilesCurlLocal(t *testing.T) {
+	abs, err := filepath.Abs("testdata/sample_file.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect, _ := ioutil.ReadFile(abs)
+
+	files := make([]RawFile, 10)
+	for i := range files {
+		files[i] = &rawFile{rawURL: "file://" + filepath.ToSlash(abs)}
+	}
+	err = FetchFilesCurl(files, "-s")
+	if err != nil {
+		t.Errorf("FetchFilesCurl:\n Expect => %v\n Got => %s\n", nil, err)
+	}
+	for i := range files {
+		if !bytes.Equal(files[i].Data(), expect) {
+			t.Errorf("FetchFilesCurl:\n Expect => %d\n Got => %d\n", len(expect), len(files[i].Data()))
+		}
+	}
+}
@@ -0,0 +1,224 @@
+// Copyright 2014 com authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package com
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Locale holds separators and unit names used by humane formatting.
+type Locale struct {
+	ThousandsSep string
+	DecimalSep   string
+	// CountUnits are suffixes of powers of 1000, starting from 1000^0.
+	CountUnits []string
+	// OrdinalSuffix returns suffix of ordinal number n, e.g. "st" of 1.
+	OrdinalSuffix func(n int64) string
+	// ShortUnits are names of day, hour, minute and second used by Duration.
+	ShortUnits [4]string
+	// Units are singular and plural names of year, month, day,
+	// hour, minute and second used by Time.
+	Units [6][2]string
+	// Now is used by Time when the difference is less than a second,
+	// Ago and Later are formats of past and future time.
+	Now, Ago, Later string
+}
+
+// EnglishLocale is the default locale.
+var EnglishLocale = &Locale{
+	ThousandsSep: ",",
+	DecimalSep:   ".",
+	CountUnits:   []string{"", "k", "M", "G", "T", "P", "E"},
+	OrdinalSuffix: func(n int64) string {
+		if n < 0 {
+			n = -n
+		}
+		switch n % 100 {
+		case 11, 12, 13:
+			return "th"
+		}
+		switch n % 10 {
+		case 1:
+			return "st"
+		case 2:
+			return "nd"
+		case 3:
+			return "rd"
+		}
+		return "th"
+	},
+	ShortUnits: [4]string{"d", "h", "m", "s"},
+	Units: [6][2]string{
+		{"year", "years"},
+		{"month", "months"},
+		{"day", "days"},
+		{"hour", "hours"},
+		{"minute", "minutes"},
+		{"second", "seconds"},
+	},
+	Now:   "just now",
+	Ago:   "%s ago",
+	Later: "in %s",
+}
+
+// DefaultLocale is used by package level humane functions.
+var DefaultLocale = EnglishLocale
+
+var (
+	durationUnits = []time.Duration{24 * time.Hour, time.Hour, time.Minute, time.Second}
+	relTimeUnits  = []time.Duration{365 * 24 * time.Hour, 30 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second}
+)
+
+// absInt64 returns absolute value of n, which is right for math.MinInt64 as well.
+func absInt64(n int64) (uint64, string) {
+	if n < 0 {
+		return uint64(-n), "-"
+	}
+	return uint64(n), ""
+}
+
+// Count formats n with suffix of power of 1000, e.g. "1.2k" and "3.4M".
+func (l *Locale) Count(n int64) string {
+	u, sign := absInt64(n)
+	if u < 1000 {
+		return sign + strconv.FormatUint(u, 10)
+	}
+
+	e := math.Floor(logn(float64(u), 1000))
+	if int(e) >= len(l.CountUnits) {
+		e = float64(len(l.CountUnits) - 1)
+	}
+	val := float64(u) / math.Pow(1000, e)
+	prec := 0
+	if val < 10 {
+		prec = 1
+	}
+	// Rounding may carry over to the next unit, e.g. 999.9k.
+	if math.Round(val*math.Pow10(prec)) >= 1000*math.Pow10(prec) && int(e)+1 < len(l.CountUnits) {
+		e++
+		val /= 1000
+		prec = 1
+	}
+
+	num := strconv.FormatFloat(val, 'f', prec, 64)
+	num = strings.TrimSuffix(num, ".0")
+	return sign + strings.Replace(num, ".", l.DecimalSep, 1) + l.CountUnits[int(e)]
+}
+
+// Comma formats n with thousands separator, e.g. "1,234,567".
+func (l *Locale) Comma(n int64) string {
+	u, sign := absInt64(n)
+	s := strconv.FormatUint(u, 10)
+
+	var buf strings.Builder
+	buf.WriteString(sign)
+	for i := range s {
+		if i > 0 && (len(s)-i)%3 == 0 {
+			buf.WriteString(l.ThousandsSep)
+		}
+		buf.WriteByte(s[i])
+	}
+	return buf.String()
+}
+
+// Ordinal formats n as ordinal number, e.g. "1st" and "22nd".
+func (l *Locale) Ordinal(n int64) string {
+	return strconv.FormatInt(n, 10) + l.OrdinalSuffix(n)
+}
+
+// Duration formats d with days, hours, minutes and seconds, e.g. "3h 12m".
+// The optional maxParts limits number of units from the largest one,
+// smaller units are truncated.
+func (l *Locale) Duration(d time.Duration, maxParts ...int) string {
+	sign := ""
+	if d < 0 {
+		sign = "-"
+	}
+	max := argInt(maxParts).Get(0, len(durationUnits))
+
+	var parts []string
+	for i, unit := range durationUnits {
+		n := d / unit
+		d -= n * unit
+		if n == 0 || len(parts) >= max {
+			continue
+		}
+		if n < 0 {
+			n = -n
+		}
+		parts = append(parts, strconv.FormatInt(int64(n), 10)+l.ShortUnits[i])
+	}
+	if len(parts) == 0 {
+		return "0" + l.ShortUnits[len(l.ShortUnits)-1]
+	}
+	return sign + strings.Join(parts, " ")
+}
+
+// Time formats t relative to ref with the largest unit, e.g. "2 days ago" and "in 3 hours".
+func (l *Locale) Time(t, ref time.Time) string {
+	diff := ref.Sub(t)
+	format := l.Ago
+	if diff < 0 {
+		diff = -diff
+		format = l.Later
+	}
+
+	for i, unit := range relTimeUnits {
+		n := int64(diff / unit)
+		if n == 0 {
+			continue
+		}
+		name := l.Units[i][1]
+		if n == 1 {
+			name = l.Units[i][0]
+		}
+		return fmt.Sprintf(format, strconv.FormatInt(n, 10)+" "+name)
+	}
+	return l.Now
+}
+
+// HumaneCount formats n with suffix of power of 1000 by DefaultLocale.
+func HumaneCount(n int64) string {
+	return DefaultLocale.Count(n)
+}
+
+// HumaneComma formats n with thousands separator by DefaultLocale.
+func HumaneComma(n int64) string {
+	return DefaultLocale.Comma(n)
+}
+
+// HumaneOrdinal formats n as ordinal number by DefaultLocale.
+func HumaneOrdinal(n int64) string {
+	return DefaultLocale.Ordinal(n)
+}
+
+// HumaneDuration formats d by DefaultLocale, see Locale.Duration for details.
+func HumaneDuration(d time.Duration, maxParts ...int) string {
+	return DefaultLocale.Duration(d, maxParts...)
+}
+
+// HumaneTime formats t relative to ref by DefaultLocale, e.g. "2 days ago".
+func HumaneTime(t, ref time.Time) string {
+	return DefaultLocale.Time(t, ref)
+}
+
+// HumaneTimeSince formats t relative to now by DefaultLocale.
+func HumaneTimeSince(t time.Time) string {
+	return DefaultLocale.Time(t, time.Now())
+}
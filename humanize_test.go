@@ -0,0 +1,111 @@
+// Copyright 2014 com authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package com
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestHumaneCount(t *testing.T) {
+	Convey("Format count with suffix", t, func() {
+		counts := map[int64]string{
+			0:             "0",
+			999:           "999",
+			-999:          "-999",
+			1000:          "1k",
+			1234:          "1.2k",
+			12345:         "12k",
+			999499:        "999k",
+			999950:        "1M",
+			3400000:       "3.4M",
+			-3400000:      "-3.4M",
+			math.MaxInt64: "9.2E",
+			math.MinInt64: "-9.2E",
+		}
+		for n, s := range counts {
+			So(HumaneCount(n), ShouldEqual, s)
+		}
+	})
+}
+
+func TestHumaneComma(t *testing.T) {
+	Convey("Format integer with thousands separator", t, func() {
+		counts := map[int64]string{
+			0:             "0",
+			123:           "123",
+			1234:          "1,234",
+			-1234567:      "-1,234,567",
+			100000:        "100,000",
+			math.MinInt64: "-9,223,372,036,854,775,808",
+		}
+		for n, s := range counts {
+			So(HumaneComma(n), ShouldEqual, s)
+		}
+	})
+}
+
+func TestHumaneOrdinal(t *testing.T) {
+	Convey("Format ordinal number", t, func() {
+		ordinals := map[int64]string{
+			0: "0th", 1: "1st", 2: "2nd", 3: "3rd", 4: "4th",
+			11: "11th", 12: "12th", 13: "13th", 21: "21st",
+			102: "102nd", 111: "111th", -1: "-1st",
+		}
+		for n, s := range ordinals {
+			So(HumaneOrdinal(n), ShouldEqual, s)
+		}
+	})
+}
+
+func TestHumaneDuration(t *testing.T) {
+	Convey("Format duration with units", t, func() {
+		So(HumaneDuration(0), ShouldEqual, "0s")
+		So(HumaneDuration(500*time.Millisecond), ShouldEqual, "0s")
+		So(HumaneDuration(3*time.Hour+12*time.Minute), ShouldEqual, "3h 12m")
+		So(HumaneDuration(50*time.Hour+5*time.Second), ShouldEqual, "2d 2h 5s")
+		So(HumaneDuration(50*time.Hour+5*time.Second, 2), ShouldEqual, "2d 2h")
+		So(HumaneDuration(-90*time.Second), ShouldEqual, "-1m 30s")
+	})
+}
+
+func TestHumaneTime(t *testing.T) {
+	Convey("Format time relative to reference", t, func() {
+		ref := time.Date(2014, 5, 6, 7, 8, 9, 0, time.UTC)
+		So(HumaneTime(ref, ref), ShouldEqual, "just now")
+		So(HumaneTime(ref.Add(-time.Second), ref), ShouldEqual, "1 second ago")
+		So(HumaneTime(ref.Add(-49*time.Hour), ref), ShouldEqual, "2 days ago")
+		So(HumaneTime(ref.Add(3*time.Hour+time.Minute), ref), ShouldEqual, "in 3 hours")
+		So(HumaneTime(ref.AddDate(-2, 0, 0), ref), ShouldEqual, "2 years ago")
+		So(HumaneTime(ref.AddDate(0, 1, 0), ref), ShouldEqual, "in 1 month")
+		So(HumaneTimeSince(time.Now().Add(-time.Minute)), ShouldEqual, "1 minute ago")
+	})
+
+	Convey("Format with custom locale", t, func() {
+		l := *EnglishLocale
+		l.ThousandsSep, l.DecimalSep = ".", ","
+		l.Units[2] = [2]string{"Tag", "Tagen"}
+		l.Ago, l.Later = "vor %s", "in %s"
+
+		ref := time.Date(2014, 5, 6, 7, 8, 9, 0, time.UTC)
+		So(l.Comma(1234567), ShouldEqual, "1.234.567")
+		So(l.Count(1500), ShouldEqual, "1,5k")
+		So(l.Time(ref.Add(-49*time.Hour), ref), ShouldEqual, "vor 2 Tagen")
+		So(EnglishLocale.Units[2][1], ShouldEqual, "days")
+	})
+}
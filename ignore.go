@@ -0,0 +1,247 @@
+// Copyright 2014 com authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package com
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// GitIgnoreFile is the name of ignore files loaded by LoadGitIgnore.
+const GitIgnoreFile = ".gitignore"
+
+// VCSDirs are directories of version control systems ignored by LoadGitIgnore.
+var VCSDirs = []string{".git", ".hg", ".svn", ".bzr"}
+
+type ignoreRule struct {
+	negate  bool
+	dirOnly bool
+	re      *regexp.Regexp
+}
+
+// parseIgnoreRule parses a line of ignore file, it returns false for
+// blank lines, comments and invalid patterns.
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	var r ignoreRule
+	line = strings.TrimSuffix(line, "\r")
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if line == "" || line[0] == '#' {
+		return r, false
+	}
+
+	if line[0] == '!' {
+		r.negate, line = true, line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly, line = true, strings.TrimRight(line, "/")
+	}
+
+	// Pattern with separator is relative to directory of the ignore file,
+	// otherwise it matches at any level below.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return r, false
+	}
+
+	// Braces are not special in ignore files.
+	var buf strings.Builder
+	if !anchored {
+		buf.WriteString("**/")
+	}
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; c {
+		case '\\':
+			buf.WriteByte(c)
+			if i+1 < len(line) {
+				i++
+				buf.WriteByte(line[i])
+			}
+		case '{', '}', ',':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		default:
+			buf.WriteByte(c)
+		}
+	}
+
+	expr, err := globToRegexp(buf.String())
+	if err != nil {
+		return r, false
+	}
+	r.re = regexp.MustCompile(expr)
+	return r, true
+}
+
+func parseIgnoreRules(lines []string) []ignoreRule {
+	rules := make([]ignoreRule, 0, len(lines))
+	for _, line := range lines {
+		if r, ok := parseIgnoreRule(line); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// GitIgnore matches slash separated relative paths with .gitignore semantics:
+// negation with "!", directory-only patterns with "/" suffix, patterns
+// anchored by "/", and "**". The last matching rule wins, rules of nested
+// ignore files take precedence over rules of parent directories, and
+// content of ignored directories is always ignored.
+//
+// It is safe for concurrent use.
+type GitIgnore struct {
+	root string
+	base []ignoreRule
+	lock sync.Mutex
+	dirs map[string][]ignoreRule // Rules of ignore file in each directory.
+}
+
+// NewGitIgnore returns a matcher of given rules, which are relative to the root.
+func NewGitIgnore(rules ...string) *GitIgnore {
+	return &GitIgnore{
+		base: parseIgnoreRules(rules),
+		dirs: make(map[string][]ignoreRule),
+	}
+}
+
+// LoadGitIgnore returns a matcher of given directory, which loads .gitignore
+// files of the directory and its subdirectories on demand, and ignores VCSDirs.
+func LoadGitIgnore(rootPath string) (*GitIgnore, error) {
+	if !IsDir(rootPath) {
+		return nil, errors.New("not a directory or does not exist: " + rootPath)
+	}
+
+	rules := make([]string, len(VCSDirs))
+	for i := range VCSDirs {
+		rules[i] = VCSDirs[i] + "/"
+	}
+	g := NewGitIgnore(rules...)
+	g.root = rootPath
+
+	// Report error of the root ignore file early.
+	lines, err := readIgnoreFile(filepath.Join(rootPath, GitIgnoreFile))
+	if err != nil {
+		return nil, err
+	}
+	g.dirs[""] = parseIgnoreRules(lines)
+	return g, nil
+}
+
+func readIgnoreFile(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+// rulesOf returns rules of ignore file in dir, which is loaded on demand.
+func (g *GitIgnore) rulesOf(dir string) []ignoreRule {
+	if g.root == "" {
+		return nil
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	rules, ok := g.dirs[dir]
+	if !ok {
+		// Unreadable nested ignore file is treated as empty like git does.
+		lines, _ := readIgnoreFile(filepath.Join(g.root, filepath.FromSlash(dir), GitIgnoreFile))
+		rules = parseIgnoreRules(lines)
+		g.dirs[dir] = rules
+	}
+	return rules
+}
+
+// matchRules reports whether name itself is ignored without checking its parents.
+func (g *GitIgnore) matchRules(name string, isDir bool) bool {
+	ignored := false
+	match := func(rules []ignoreRule, rel string) {
+		for _, r := range rules {
+			if r.dirOnly && !isDir {
+				continue
+			}
+			if r.re.MatchString(rel) {
+				ignored = !r.negate
+			}
+		}
+	}
+
+	match(g.base, name)
+	dir := ""
+	for {
+		rel := name
+		if dir != "" {
+			rel = name[len(dir)+1:]
+		}
+		match(g.rulesOf(dir), rel)
+
+		i := strings.IndexByte(rel, '/')
+		if i == -1 {
+			break
+		}
+		dir = path.Join(dir, rel[:i])
+	}
+	return ignored
+}
+
+// Match reports whether the slash separated relative path is ignored.
+func (g *GitIgnore) Match(name string, isDir bool) bool {
+	name = strings.Trim(name, "/")
+	if name == "" {
+		return false
+	}
+
+	for i := 0; i < len(name); i++ {
+		if name[i] == '/' && g.matchRules(name[:i], true) {
+			return true
+		}
+	}
+	return g.matchRules(name, isDir)
+}
+
+// Exclude reports whether the entry is ignored, it can be used as
+// WalkOptions.Exclude, CopyDirOptions.Exclude or with StatDirExclude,
+// so ignored directories are not read at all.
+func (g *GitIgnore) Exclude(e *WalkEntry) bool {
+	return g.Match(e.Path, e.IsDir())
+}
+
+// Filter reports whether the path is ignored, directories have suffix '/'
+// like results of StatDir. It can be used as filter of CopyDir,
+// but prefer Exclude with CopyDirWith to skip content of ignored directories.
+func (g *GitIgnore) Filter(filePath string) bool {
+	return g.Match(filePath, strings.HasSuffix(filePath, "/"))
+}
@@ -0,0 +1,151 @@
+// Copyright 2014 com authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package com
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestGitIgnore(t *testing.T) {
+	Convey("Match paths with gitignore rules", t, func() {
+		ig := NewGitIgnore(
+			"# comment",
+			"",
+			"*.log",
+			"!keep.log",
+			"build/",
+			"/root.txt",
+			"docs/*.html",
+			"**/tmp/**",
+			`\#hash`,
+			"trailing   ",
+			"{a,b}",
+		)
+		cases := []struct {
+			name  string
+			isDir bool
+			match bool
+		}{
+			{"a.log", false, true},
+			{"sub/a.log", false, true},
+			{"keep.log", false, false},
+			{"sub/keep.log", false, false},
+			{"build", true, true},
+			{"build", false, false},
+			{"src/build", true, true},
+			{"build/out.bin", false, true},
+			{"root.txt", false, true},
+			{"sub/root.txt", false, false},
+			{"docs/index.html", false, true},
+			{"docs/api/index.html", false, false},
+			{"x/tmp/y/z", false, true},
+			{"#hash", false, true},
+			{"trailing", false, true},
+			{"{a,b}", false, true},
+			{"a", false, false},
+			{"comment", false, false},
+			{"", true, false},
+		}
+		for _, c := range cases {
+			So(ig.Match(c.name, c.isDir), ShouldEqual, c.match)
+		}
+	})
+
+	Convey("Cannot re-include content of ignored directory", t, func() {
+		ig := NewGitIgnore("vendor/", "!vendor/keep.go")
+		So(ig.Match("vendor/keep.go", false), ShouldBeTrue)
+
+		ig = NewGitIgnore("vendor/*", "!vendor/keep.go")
+		So(ig.Match("vendor/keep.go", false), ShouldBeFalse)
+		So(ig.Match("vendor/other.go", false), ShouldBeTrue)
+	})
+}
+
+func TestLoadGitIgnore(t *testing.T) {
+	root := makeWalkTree(t,
+		".gitignore", ".git/HEAD", "main.go", "app.log", "bin/app",
+		"web/.gitignore", "web/index.js", "web/dist/app.js", "web/debug.log", "web/keep.log",
+		"lib/dist/lib.go")
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(".gitignore", "*.log\nbin/\n")
+	write("web/.gitignore", "/dist\n!keep.log\n")
+
+	Convey("Load nested ignore files on demand", t, func() {
+		ig, err := LoadGitIgnore(root)
+		So(err, ShouldBeNil)
+		So(ig.Match(".git", true), ShouldBeTrue)
+		So(ig.Match("app.log", false), ShouldBeTrue)
+		So(ig.Match("web/dist/app.js", false), ShouldBeTrue)
+		So(ig.Match("lib/dist/lib.go", false), ShouldBeFalse)
+		So(ig.Match("web/debug.log", false), ShouldBeTrue)
+		So(ig.Match("web/keep.log", false), ShouldBeFalse)
+
+		_, err = LoadGitIgnore(filepath.Join(root, "404"))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Plug into StatDir and CopyDir", t, func() {
+		ig, err := LoadGitIgnore(root)
+		So(err, ShouldBeNil)
+
+		files, err := StatDirExclude(root, ig.Exclude, true)
+		So(err, ShouldBeNil)
+		So(files, ShouldResemble, []string{
+			".gitignore", "lib/", "lib/dist/", "lib/dist/lib.go", "main.go",
+			"web/", "web/.gitignore", "web/index.js", "web/keep.log",
+		})
+
+		dest := filepath.Join(t.TempDir(), "copy")
+		So(CopyDir(root, dest, ig.Filter), ShouldBeNil)
+		copied, err := StatDir(dest, true)
+		So(err, ShouldBeNil)
+		So(copied, ShouldResemble, files)
+	})
+
+	Convey("Ignored directories are not read by CopyDirWith", t, func() {
+		ig, err := LoadGitIgnore(root)
+		So(err, ShouldBeNil)
+
+		var seen []string
+		dest := filepath.Join(t.TempDir(), "copy")
+		So(CopyDirWith(root, dest, CopyDirOptions{
+			Exclude: func(e *WalkEntry) bool {
+				seen = append(seen, e.Path)
+				return ig.Exclude(e)
+			},
+		}), ShouldBeNil)
+		So(seen, ShouldContain, ".git")
+		So(seen, ShouldContain, "web/dist")
+		for _, p := range seen {
+			So(p, ShouldNotStartWith, ".git/")
+			So(p, ShouldNotStartWith, "bin/")
+			So(p, ShouldNotStartWith, "web/dist/")
+		}
+
+		copied, err := StatDir(dest, true)
+		So(err, ShouldBeNil)
+		files, err := StatDirExclude(root, ig.Exclude, true)
+		So(err, ShouldBeNil)
+		So(copied, ShouldResemble, files)
+	})
+}
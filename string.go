@@ -20,6 +20,7 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"errors"
+	"fmt"
 	r "math/rand"
 	"strconv"
 	"strings"
@@ -251,3 +252,179 @@ func ToSnakeCase(str string) string {
 
 	return buf.String()
 }
+
+// isShellNameByte returns true if c can be part of a shell variable name.
+func isShellNameByte(c byte, first bool) bool {
+	return c == '_' || IsLetter(c) || !first && c >= '0' && c <= '9'
+}
+
+// expandShellVar expands variable reference at the start of s, which starts
+// with '$'. It returns number of bytes consumed, or 0 if s is a literal '$'.
+func expandShellVar(s string, vars map[string]string) (int, string, error) {
+	if len(s) < 2 {
+		return 0, "", nil
+	}
+	switch c := s[1]; {
+	case c == '{':
+		end := strings.IndexByte(s, '}')
+		if end < 0 {
+			return 0, "", errors.New("shell: unterminated ${")
+		}
+		name := s[2:end]
+		for i := 0; i < len(name); i++ {
+			if !isShellNameByte(name[i], i == 0) {
+				return 0, "", fmt.Errorf("shell: unsupported parameter expansion: %s", s[:end+1])
+			}
+		}
+		if len(name) == 0 {
+			return 0, "", errors.New("shell: empty parameter expansion: ${}")
+		}
+		return end + 1, vars[name], nil
+	case c == '(':
+		return 0, "", errors.New("shell: command substitution is not supported")
+	case isShellNameByte(c, true):
+		end := 2
+		for end < len(s) && isShellNameByte(s[end], false) {
+			end++
+		}
+		return end, vars[s[1:end]], nil
+	}
+	return 0, "", nil
+}
+
+// SplitShellArgs splits command line into arguments like a POSIX shell,
+// but without running one. It supports single and double quotes, backslash
+// escapes, line continuations and comments. When vars is not nil, $NAME and
+// ${NAME} are expanded from it, missing variables are empty and expanded
+// values are never split. Shell operators like pipes, redirections and
+// command substitution are not supported and cause an error if unquoted.
+func SplitShellArgs(s string, vars map[string]string) ([]string, error) {
+	var args []string
+	var word []byte
+	inWord := false // Quotes start a word even if it stays empty.
+	flush := func() {
+		if inWord {
+			args = append(args, string(word))
+			word = word[:0]
+			inWord = false
+		}
+	}
+
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			flush()
+
+		case c == '#' && !inWord:
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+
+		case c == '\\':
+			i++
+			if i == len(s) {
+				return nil, errors.New("shell: trailing backslash")
+			}
+			if s[i] != '\n' {
+				word = append(word, s[i])
+				inWord = true
+			}
+
+		case c == '\'':
+			end := strings.IndexByte(s[i+1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("shell: unterminated single quote at offset %d", i)
+			}
+			word = append(word, s[i+1:i+1+end]...)
+			inWord = true
+			i += end + 1
+
+		case c == '"':
+			start := i
+			inWord = true
+			for i++; ; i++ {
+				if i == len(s) {
+					return nil, fmt.Errorf("shell: unterminated double quote at offset %d", start)
+				}
+				c = s[i]
+				if c == '"' {
+					break
+				}
+
+				switch {
+				case c == '\\' && i+1 < len(s) && strings.IndexByte("$`\"\\\n", s[i+1]) > -1:
+					i++
+					if s[i] != '\n' {
+						word = append(word, s[i])
+					}
+				case c == '`':
+					return nil, errors.New("shell: command substitution is not supported")
+				case c == '$' && vars != nil:
+					n, val, err := expandShellVar(s[i:], vars)
+					if err != nil {
+						return nil, err
+					}
+					if n == 0 {
+						word = append(word, c)
+						continue
+					}
+					word = append(word, val...)
+					i += n - 1
+				default:
+					word = append(word, c)
+				}
+			}
+
+		case c == '$' && vars != nil:
+			n, val, err := expandShellVar(s[i:], vars)
+			if err != nil {
+				return nil, err
+			}
+			if n == 0 {
+				word = append(word, c)
+				inWord = true
+				continue
+			}
+			// Unquoted empty value does not make a word.
+			if len(val) > 0 {
+				word = append(word, val...)
+				inWord = true
+			}
+			i += n - 1
+
+		case strings.IndexByte("|&;<>()`", c) > -1:
+			return nil, fmt.Errorf("shell: unsupported operator %q at offset %d", c, i)
+
+		default:
+			word = append(word, c)
+			inWord = true
+		}
+	}
+	flush()
+	return args, nil
+}
+
+// ShellQuote returns s quoted for a POSIX shell if needed,
+// so it can be safely displayed, logged or passed to SplitShellArgs.
+func ShellQuote(s string) string {
+	if len(s) == 0 {
+		return "''"
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !isShellNameByte(c, false) && strings.IndexByte("@%+=:,./-", c) < 0 {
+			return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+		}
+	}
+	return s
+}
+
+// JoinShellArgs returns arguments quoted by ShellQuote and joined by spaces,
+// it is the inverse of SplitShellArgs.
+func JoinShellArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = ShellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
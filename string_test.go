@@ -128,6 +128,61 @@ func Test_ToSnakeCase(t *testing.T) {
 	})
 }
 
+func TestSplitShellArgs(t *testing.T) {
+	Convey("Split command line like a POSIX shell", t, func() {
+		vars := map[string]string{"NAME": "com", "EMPTY": "", "SPACED": "a b"}
+		cases := []struct {
+			line   string
+			expect []string
+		}{
+			{"", nil},
+			{"  git   log  ", []string{"git", "log"}},
+			{`echo 'it''s' "say \"hi\"" a\ b`, []string{"echo", "its", `say "hi"`, "a b"}},
+			{`echo '' "" x""`, []string{"echo", "", "", "x"}},
+			{`printf '$NAME \n' "\$NAME \n"`, []string{"printf", "$NAME \\n", "$NAME \\n"}},
+			{"a \\\nb", []string{"a", "b"}},
+			{"run $NAME ${NAME}-x \"$NAME\"", []string{"run", "com", "com-x", "com"}},
+			{"run $EMPTY \"$EMPTY\" $SPACED $MISSING $1 $", []string{"run", "", "a b", "$1", "$"}},
+			{"ls # list files\npwd", []string{"ls", "pwd"}},
+			{"a#b", []string{"a#b"}},
+			{"grep '|' \"a;b\"", []string{"grep", "|", "a;b"}},
+		}
+		for _, c := range cases {
+			args, err := SplitShellArgs(c.line, vars)
+			So(err, ShouldBeNil)
+			So(args, ShouldResemble, c.expect)
+		}
+
+		args, err := SplitShellArgs("echo $NAME", nil)
+		So(err, ShouldBeNil)
+		So(args, ShouldResemble, []string{"echo", "$NAME"})
+
+		for _, line := range []string{
+			"echo 'open", `echo "open`, "echo \\", "a | b", "a > b",
+			"a; b", "echo `id`", "echo \"`id`\"", "echo $(id)", "${NAME", "${NAME:-x}",
+		} {
+			_, err = SplitShellArgs(line, vars)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
+
+func TestJoinShellArgs(t *testing.T) {
+	Convey("Quote arguments for a POSIX shell", t, func() {
+		So(ShellQuote(""), ShouldEqual, "''")
+		So(ShellQuote("--name=a/b.c"), ShouldEqual, "--name=a/b.c")
+		So(ShellQuote("it's"), ShouldEqual, `'it'\''s'`)
+		So(ShellQuote("$HOME"), ShouldEqual, "'$HOME'")
+
+		args := []string{"echo", "", "a b", "it's", `"$x"`, "~", "a\nb"}
+		line := JoinShellArgs(args)
+		So(line, ShouldEqual, `echo '' 'a b' 'it'\''s' '"$x"' '~' 'a`+"\n"+`b'`)
+		split, err := SplitShellArgs(line, map[string]string{})
+		So(err, ShouldBeNil)
+		So(split, ShouldResemble, args)
+	})
+}
+
 func BenchmarkIsLetter(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		IsLetter('a')